@@ -0,0 +1,171 @@
+package xsx
+
+import "bytes"
+
+// CommentStart is the byte that starts a comment if comments are enabled.
+// Without a following brace, the comment extends to the end of the line. If
+// the CommentStart is immediately followed by an opening brace, the comment
+// is a block comment that ends with the matching closing brace. Block
+// comments nest with all kinds of braces outside of quoted atoms, e.g.
+//
+//	; line comment
+//	(foo ;(comment with "unbalanced :)" and (nested) braces) bar)
+//
+// A comment starts only where an atom could start, i.e. "foo;bar" is a
+// single atom.
+const CommentStart = ';'
+
+// CommentFunc is called by Scanner when a comment is detected. Text is the
+// comment without the CommentStart and, for block comments, without the
+// outermost braces. Brace is the opening brace of a block comment and 0 for
+// line comments.
+type CommentFunc func(text []byte, brace byte)
+
+// CommentHandler can be implemented by a Scanner's Handler to receive
+// comments.
+type CommentHandler interface {
+	Comment(text []byte, brace byte) error
+}
+
+type commentMode int8
+
+const (
+	cmtNone  commentMode = iota
+	cmtStart             // CommentStart seen, kind of comment not yet known
+	cmtLine
+	cmtBlock
+)
+
+// commentState keeps track of a comment across Scan calls.
+type commentState struct {
+	mode   commentMode
+	report bool // comment text is buffered to be reported
+	quote  bool // in a quoted atom in a block comment
+	esc    bool
+	brace  byte
+	depth  int
+	start  Position
+	text   []byte
+}
+
+func (s *Scanner) reportsComments() bool {
+	if s.Comment != nil {
+		return true
+	}
+	_, ok := s.Handler.(CommentHandler)
+	return ok
+}
+
+// startComment starts a comment at txt[rp] and scans it as far as possible.
+func (s *Scanner) startComment(txt []byte, rp int64) (int64, bool, error) {
+	s.cmt.mode = cmtStart
+	s.cmt.brace = 0
+	s.cmt.report = s.reportsComments()
+	s.cmt.start = s.at(rp)
+	s.cmt.text = s.cmt.text[:0]
+	return s.comment(txt, rp+1)
+}
+
+// comment scans the current comment from txt[rp]. It returns the read
+// position after the comment and true if the comment ended in txt.
+func (s *Scanner) comment(txt []byte, rp int64) (_ int64, done bool, err error) {
+	end := int64(len(txt))
+	if s.cmt.mode == cmtStart {
+		if rp >= end {
+			return rp, false, nil
+		}
+		if c := txt[rp]; isAny(c, ccBegin) {
+			s.cmt.mode = cmtBlock
+			s.cmt.brace = c
+			s.cmt.depth = 1
+			s.cmt.quote, s.cmt.esc = false, false
+			rp++
+		} else {
+			s.cmt.mode = cmtLine
+			s.cmt.brace = 0
+		}
+	}
+	start := rp
+	if s.cmt.mode == cmtLine {
+		nl := bytes.IndexByte(txt[rp:], '\n')
+		if nl < 0 {
+			return end, false, s.commentText(txt, txt[start:])
+		}
+		rp += int64(nl)
+		if err = s.commentText(txt, txt[start:rp]); err != nil {
+			return rp, false, err
+		}
+		return rp, true, s.callComment(txt, rp)
+	}
+	for ; rp < end; rp++ {
+		c := txt[rp]
+		switch {
+		case s.cmt.esc:
+			s.cmt.esc = false
+		case s.cmt.quote:
+			switch c {
+			case '\\':
+				s.cmt.esc = true
+			case '"':
+				s.cmt.quote = false
+			}
+		case c == '"':
+			s.cmt.quote = true
+		case isAny(c, ccBegin):
+			s.cmt.depth++
+		case isAny(c, ccEnd):
+			if s.cmt.depth--; s.cmt.depth == 0 {
+				if err = s.commentText(txt, txt[start:rp]); err != nil {
+					return rp, false, err
+				}
+				rp++
+				return rp, true, s.callComment(txt, rp)
+			}
+		}
+	}
+	return end, false, s.commentText(txt, txt[start:])
+}
+
+// commentText buffers a piece of comment text if comments are reported.
+func (s *Scanner) commentText(txt, text []byte) error {
+	if !s.cmt.report {
+		return nil
+	}
+	s.cmt.text = append(s.cmt.text, text...)
+	if s.MaxAtomLen > 0 && len(s.cmt.text) > s.MaxAtomLen {
+		return s.limitError(txt, s.cmt.start, "comment length", s.MaxAtomLen)
+	}
+	return nil
+}
+
+// callComment reports the complete comment that ends before txt[rp].
+func (s *Scanner) callComment(txt []byte, rp int64) (err error) {
+	s.cmt.mode = cmtNone
+	if !s.cmt.report {
+		return nil
+	}
+	text := s.cmt.text
+	if s.cmt.brace == 0 {
+		text = bytes.TrimSuffix(text, []byte{'\r'})
+	}
+	s.tokStart = s.cmt.start
+	if txt == nil {
+		s.tokEnd = s.cur
+	} else {
+		s.tokEnd = s.at(rp)
+	}
+	if s.Comment != nil {
+		s.Comment(text, s.cmt.brace)
+		return nil
+	}
+	return s.callbackError(s.Handler.(CommentHandler).Comment(text, s.cmt.brace), txt)
+}
+
+// finishComment is called by Finish for a pending comment.
+func (s *Scanner) finishComment() error {
+	if s.cmt.mode == cmtBlock {
+		return s.scanError(ScanUnterminatedComment, s.cmt.start, nil,
+			"unterminated block comment", nil)
+	}
+	return s.callComment(nil, 0)
+}
@@ -0,0 +1,156 @@
+package xsx
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stvp/assert"
+)
+
+func commentScanner(wr *bytes.Buffer) *Scanner {
+	s := NewScanner(
+		func(meta bool, brace byte) { fmt.Fprintf(wr, "begin %t %c\n", meta, brace) },
+		func(meta bool, brace byte) { fmt.Fprintf(wr, "end %c\n", brace) },
+		func(meta bool, atom []byte, quoted bool) { fmt.Fprintf(wr, "atom %t '%s'\n", meta, atom) },
+	)
+	s.Comments = true
+	s.Comment = func(text []byte, brace byte) {
+		if brace == 0 {
+			fmt.Fprintf(wr, "line '%s'\n", text)
+		} else {
+			fmt.Fprintf(wr, "block %c '%s'\n", brace, text)
+		}
+	}
+	return s
+}
+
+func ExampleScanner_Comment() {
+	var out bytes.Buffer
+	s := commentScanner(&out)
+	mustExample(s.ScanString(`; config
+(server a;b ;(port "8080)" (old)) \;[meta?]
+  8081) ; trailing`))
+	os.Stdout.Write(out.Bytes())
+	// Output:
+	// line ' config'
+	// begin false (
+	// atom false 'server'
+	// atom false 'a;b'
+	// block ( 'port "8080)" (old)'
+	// atom false '\'
+	// block [ 'meta?'
+	// atom false '8081'
+	// end )
+	// line ' trailing'
+}
+
+func TestScanner_CommentSplit(t *testing.T) {
+	checkSplitInvariant(t, commentScanner, "(a ;line\r\n;(b \"c)\\\"\" {d}) \\;x\n e;f ;[]);")
+}
+
+func TestScanner_CommentDisabled(t *testing.T) {
+	var out bytes.Buffer
+	s := commentScanner(&out)
+	s.Comments = false
+	assert.Nil(t, s.ScanString("a ;b"))
+	assert.Equal(t, "atom false 'a'\natom false ';b'\n", out.String())
+	out.Reset()
+	s = commentScanner(&out)
+	s.Comment = nil
+	assert.Nil(t, s.ScanString("a ;b\n;(c) d"))
+	assert.Equal(t, "atom false 'a'\natom false 'd'\n", out.String())
+}
+
+func TestScanner_CommentUnterminated(t *testing.T) {
+	var out bytes.Buffer
+	s := commentScanner(&out)
+	err := s.ScanString("a\n ;(b (c)")
+	assert.True(t, errors.Is(err, ScanUnterminatedComment), err)
+	assert.Equal(t, "2:2", err.(*ScanError).Where())
+}
+
+func ExamplePullParser_PullComments() {
+	pp := NewPullParser(strings.NewReader("(a ;(note) b) ; end"))
+	pp.Comments = true
+	pp.PullComments = true
+	for tok, err := range pp.Tokens() {
+		mustExample(err)
+		switch tok {
+		case TokAtom:
+			fmt.Println(tok, pp.Atom)
+		case TokComment:
+			fmt.Printf("%s %q %q\n", tok, pp.LastBrace(), pp.Atom)
+		default:
+			fmt.Printf("%s %c\n", tok, pp.LastBrace())
+		}
+	}
+	// Output:
+	// begin (
+	// atom a
+	// comment '(' "note"
+	// atom b
+	// end )
+	// comment '\x00' " end"
+}
+
+func TestPullParser_CommentsSkipped(t *testing.T) {
+	pp := NewPullParserSize(strings.NewReader("(a ;(note) b) ; end"), 2)
+	pp.Comments = true
+	assert.Equal(t, "false( false[a]false false[b]false ) ", pullAll(t, pp))
+}
+
+func ExampleCommentPrinter() {
+	for _, p := range []Printer{
+		Compact(os.Stdout),
+		Indenting(os.Stdout, "  "),
+		Pretty(os.Stdout, "  "),
+	} {
+		mustExample(Write(p,
+			Comment{Text: " two\n lines"},
+			B('('), "a", Comment{Text: "block", Brace: '['}, "b",
+			Comment{Text: " line"},
+			"c", End,
+		))
+		fmt.Println()
+	}
+	// Output:
+	// ; two
+	// ; lines
+	// (a ;[block]b ; line
+	// c)
+	// ; two
+	// ; lines
+	// (a ;[block] b ; line
+	// c)
+	// ; two
+	// ; lines
+	// (
+	//   a
+	//   ;[block]
+	//   b
+	//   ; line
+	//   c
+	// )
+}
+
+func TestCommentPrinter_roundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	p := Indenting(&buf, "\t")
+	assert.Nil(t, Write(p, B('('), "a", Comment{Text: "x\ny"}, Nl{1, 1}, "b",
+		Comment{Text: `"q)" (n)`, Brace: '{'}, End))
+	var out bytes.Buffer
+	s := commentScanner(&out)
+	assert.Nil(t, s.ScanString(buf.String()), buf.String())
+	assert.Equal(t, `begin false (
+atom false 'a'
+line 'x'
+line 'y'
+atom false 'b'
+block { '"q)" (n)'
+end )
+`, out.String())
+}
@@ -0,0 +1,49 @@
+package xsx
+
+import "io"
+
+// printHandler is the Handler of a Scanner created with NewPrinterScanner.
+type printHandler struct {
+	p   Printer
+	scn *Scanner
+}
+
+func (h *printHandler) Begin(isMeta bool, brace byte) error {
+	return h.p.Begin(rune(brace), isMeta)
+}
+
+func (h *printHandler) End(isMeta bool, brace byte) error { return h.p.End() }
+
+func (h *printHandler) Atom(isMeta bool, atom []byte, quoted bool) error {
+	quote := Qcond
+	switch {
+	case h.scn.IsRaw():
+		quote = Qraw
+	case quoted:
+		quote = Qforce
+	}
+	return h.p.Atom(string(atom), isMeta, quote)
+}
+
+func (h *printHandler) Comment(text []byte, brace byte) error {
+	return printerComment(h.p, string(text), brace)
+}
+
+// NewPrinterScanner creates a Scanner that writes the scanned input to p.
+// Meta flags are kept, quoted atoms are written with Qforce and raw atoms
+// with Qraw. If Comments is enabled on the Scanner and p is a
+// CommentPrinter, comments are written to p too. As the Scanner passes each
+// event directly to p, input of any size can be reformatted in constant
+// memory, as long as p does not buffer, e.g. CompactPrinter or
+// IndentingPrinter.
+func NewPrinterScanner(p Printer) *Scanner {
+	h := &printHandler{p: p}
+	h.scn = NewHandlerScanner(h)
+	return h.scn
+}
+
+// Copy reads XSX from src and writes it to dst, see NewPrinterScanner. Copy
+// does not close dst.
+func Copy(dst Printer, src io.Reader) error {
+	return NewPrinterScanner(dst).Read(src)
+}
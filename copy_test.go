@@ -0,0 +1,66 @@
+package xsx
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stvp/assert"
+)
+
+func ExampleCopy() {
+	in := strings.NewReader(`(server main (listen [localhost 8080])  \(tls "cert file"))`)
+	if err := Copy(Pretty(os.Stdout, "  "), in); err != nil {
+		os.Stdout.WriteString(err.Error())
+	}
+	// Output:
+	// (
+	//   server
+	//   main
+	//   (
+	//     listen
+	//     [
+	//       localhost
+	//       8080
+	//     ]
+	//   )
+	//   \(
+	//     tls
+	//     "cert file"
+	//   )
+	// )
+}
+
+func TestNewPrinterScanner(t *testing.T) {
+	const in = `(a "b" ;comment
+	\#"c\d"# ;{block} "" \"e")`
+	var sb strings.Builder
+	scn := NewPrinterScanner(Compact(&sb))
+	scn.Comments = true
+	scn.RawAtoms = true
+	assert.Nil(t, scn.Read(iotest.OneByteReader(strings.NewReader(in))))
+	assert.Equal(t, `(a "b" ;comment
+\#"c\d"# ;{block}"" \"e")`, sb.String())
+}
+
+func TestCopy(t *testing.T) {
+	const in = "x (a [b {c}] \\d) \"y z\""
+	var sb strings.Builder
+	assert.Nil(t, Copy(Compact(&sb), iotest.HalfReader(strings.NewReader(in))))
+	assert.Equal(t, `x(a[b{c}]\d)"y z"`, sb.String())
+
+	err := Copy(Compact(&sb), strings.NewReader("(a b"))
+	assert.True(t, errors.Is(err, ScanTruncated), err)
+
+	err = Copy(failAtoms{Compact(&sb)}, strings.NewReader("(a)"))
+	assert.True(t, errors.Is(err, ScanCallback), err)
+	assert.True(t, errors.Is(err, errFailAtoms), err)
+}
+
+var errFailAtoms = errors.New("atoms fail")
+
+type failAtoms struct{ Printer }
+
+func (failAtoms) Atom(string, bool, QuoteMode) error { return errFailAtoms }
@@ -0,0 +1,105 @@
+// Package cst provides a Concrete Syntax Tree for XSX that keeps the layout
+// of its input. Besides the expressions, the tree records the white space and
+// comments around each node, the quote style and the exact spelling of atoms.
+// Writing an unmodified tree reproduces its input byte for byte. Edited nodes
+// are written from their current values while all other nodes keep their
+// original formatting.
+package cst
+
+// Trivia is the text around a node that does not change its meaning, i.e.
+// white space and comments.
+type Trivia struct {
+	// Leading is the text between the previous token and the node.
+	Leading string
+	// Trailing is the text after the node up to and including the end of
+	// the node's line, if that line has no further tokens.
+	Trailing string
+}
+
+func (t *Trivia) trivia() *Trivia { return t }
+
+// Node is either an *Atom or a *Seq.
+type Node interface {
+	trivia() *Trivia
+}
+
+// QuoteStyle is the form in which an atom is written.
+type QuoteStyle int
+
+const (
+	// Plain atoms are not quoted. A Plain atom that must be quoted to be read
+	// back is written as Quoted atom.
+	Plain QuoteStyle = iota
+	// Quoted atoms are enclosed in '"'.
+	Quoted
+	// Raw atoms are written as raw atoms, see xsx.RawStart.
+	Raw
+)
+
+// Atom is an atom node. Text is the value of the atom, i.e. without quotes
+// and escapes.
+type Atom struct {
+	Trivia
+	Meta  bool
+	Text  string
+	Style QuoteStyle
+	// the atom as read from input
+	src      string
+	srcMeta  bool
+	srcText  string
+	srcStyle QuoteStyle
+}
+
+// NewAtom returns a Plain atom node without trivia.
+func NewAtom(text string) *Atom { return &Atom{Text: text} }
+
+// Modified reports whether a was changed after it was read or if a was not
+// read from input at all.
+func (a *Atom) Modified() bool {
+	return a.src == "" ||
+		a.Meta != a.srcMeta ||
+		a.Text != a.srcText ||
+		a.Style != a.srcStyle
+}
+
+// Seq is a sequence node.
+type Seq struct {
+	Trivia
+	Meta bool
+	// Brace is the opening brace of the sequence, '(' if 0.
+	Brace byte
+	Elems []Node
+	// End is the text between the last element and the closing brace.
+	End string
+}
+
+// NewSeq returns a sequence node without trivia.
+func NewSeq(brace byte, elems ...Node) *Seq {
+	return &Seq{Brace: brace, Elems: elems}
+}
+
+// Head returns the first element of s if it is an atom, nil otherwise.
+func (s *Seq) Head() *Atom {
+	if len(s.Elems) == 0 {
+		return nil
+	}
+	a, _ := s.Elems[0].(*Atom)
+	return a
+}
+
+// Options select the XSX dialect of the input, see the fields of the same
+// name in xsx.Scanner. Escapes also applies to modified atoms when writing.
+type Options struct {
+	Comments bool
+	Escapes  bool
+	UTF8     bool
+	RawAtoms bool
+}
+
+// File is the syntax tree of a complete input.
+type File struct {
+	Nodes []Node
+	// End is the text after the last node.
+	End  string
+	opts Options
+}
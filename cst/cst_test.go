@@ -0,0 +1,117 @@
+package cst
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"git.fractalqb.de/fractalqb/xsx"
+	"github.com/stvp/assert"
+)
+
+const testConfig = `; server configuration
+(server  main ; the main server
+	(listen [localhost 8080]
+	        ["::"     8081])   ;( old: (listen *) )
+
+	\(tls #"C:\certs\main.pem"# "with \"quotes\"")
+  \ ignored  )
+{env prod}`
+
+func ExampleParse() {
+	f, err := ParseString(testConfig, &Options{Comments: true, RawAtoms: true})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	listen := f.Nodes[0].(*Seq).Elems[2].(*Seq)
+	port := listen.Elems[2].(*Seq).Elems[1].(*Atom)
+	port.Text = "9090"
+	listen.Elems = append(listen.Elems, NewSeq('[', NewAtom("a host"), NewAtom("80")))
+	fmt.Println(f)
+	// Output:
+	// ; server configuration
+	// (server  main ; the main server
+	// 	(listen [localhost 8080]
+	// 	        ["::"     9090]["a host" 80])   ;( old: (listen *) )
+	//
+	// 	\(tls #"C:\certs\main.pem"# "with \"quotes\"")
+	//   \ ignored  )
+	// {env prod}
+}
+
+func TestParse_roundTrip(t *testing.T) {
+	opts := []*Options{
+		nil,
+		{Comments: true},
+		{Comments: true, Escapes: true, RawAtoms: true, UTF8: true},
+	}
+	for _, in := range []string{
+		"",
+		" \n\t",
+		"a",
+		"  a  ",
+		"(a b)",
+		"\r\n( a\r\n  b ) \r\n",
+		"\"a\"b\"c\"",
+		"(\n)\n\n[ ]{\n\n}\\(x)\\\\y",
+		"(a ; comment\n b ;(block\n comment)\n) ;end",
+		"(\"esc\\\\\" #\"raw\"# \\#\"meta raw\"#)\n",
+		"x\u00a0y\u2028z",
+	} {
+		for _, o := range opts {
+			f, err := ParseString(in, o)
+			if err != nil {
+				t.Fatalf("%q: %s", in, err)
+			}
+			assert.Equal(t, in, f.String(), fmt.Sprintf("%q %+v", in, o))
+		}
+	}
+}
+
+func TestParse_trivia(t *testing.T) {
+	f, err := ParseString("a ;1\n  b\n\n(c ;2\n d ;3\n) ;4\n;5\n", &Options{Comments: true})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(f.Nodes))
+	a, b := f.Nodes[0].(*Atom), f.Nodes[1].(*Atom)
+	assert.Equal(t, Trivia{"", " ;1\n"}, a.Trivia)
+	assert.Equal(t, Trivia{"  ", "\n"}, b.Trivia)
+	f, err = ParseString("(c ;2\n d ;3\n) ;4\n;5\n", &Options{Comments: true})
+	assert.Nil(t, err)
+	s := f.Nodes[0].(*Seq)
+	assert.Equal(t, Trivia{"", " ;4\n"}, s.Trivia)
+	assert.Equal(t, "", s.End)
+	assert.Equal(t, Trivia{"", " ;2\n"}, s.Elems[0].(*Atom).Trivia)
+	assert.Equal(t, Trivia{" ", " ;3\n"}, s.Elems[1].(*Atom).Trivia)
+	assert.Equal(t, ";5\n", f.End)
+}
+
+func TestWriteTo_edits(t *testing.T) {
+	f, err := ParseString(`(a "b" #"c"# d)`, &Options{RawAtoms: true})
+	assert.Nil(t, err)
+	s := f.Nodes[0].(*Seq)
+	s.Elems[0].(*Atom).Text = "a a"
+	s.Elems[1].(*Atom).Style = Plain
+	s.Elems[2].(*Atom).Text = `c"#`
+	s.Elems[3].(*Atom).Meta = true
+	s.Elems = append(s.Elems, NewAtom("e"), &Atom{Text: "f", Style: Raw}, NewAtom("g"))
+	assert.Equal(t, `("a a" b ##"c"#"## \d e #"f"# g)`, f.String())
+
+	f, err = ParseString("x", &Options{Escapes: true})
+	assert.Nil(t, err)
+	f.Nodes[0].(*Atom).Text = "two\nlines"
+	assert.Equal(t, `"two\nlines"`, f.String())
+
+	f.Nodes = append(f.Nodes, &Seq{Brace: '<'})
+	_, err = f.WriteTo(new(errWriter))
+	assert.True(t, err != nil)
+}
+
+type errWriter struct{}
+
+func (errWriter) Write([]byte) (int, error) { return 0, errors.New("write failed") }
+
+func TestParse_error(t *testing.T) {
+	_, err := ParseString("(a b", nil)
+	assert.True(t, errors.Is(err, xsx.ScanTruncated), err)
+}
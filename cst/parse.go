@@ -0,0 +1,106 @@
+package cst
+
+import (
+	"bytes"
+
+	"git.fractalqb.de/fractalqb/xsx"
+)
+
+// builder is the xsx.Handler that builds the syntax tree.
+type builder struct {
+	src   []byte
+	scn   *xsx.Scanner
+	file  *File
+	stack []*Seq
+	prev  *Trivia // the node before the current gap, if it may get trailing trivia
+	last  int64   // offset after the last token
+}
+
+// gap returns the text between the last token and the token at start. Its
+// first line becomes the trailing trivia of the previous node.
+func (b *builder) gap(start int64) string {
+	gap := b.src[b.last:start]
+	if b.prev != nil {
+		if nl := bytes.IndexByte(gap, '\n'); nl >= 0 {
+			b.prev.Trailing = string(gap[:nl+1])
+			gap = gap[nl+1:]
+		}
+		b.prev = nil
+	}
+	return string(gap)
+}
+
+func (b *builder) add(n Node) {
+	if l := len(b.stack); l > 0 {
+		s := b.stack[l-1]
+		s.Elems = append(s.Elems, n)
+	} else {
+		b.file.Nodes = append(b.file.Nodes, n)
+	}
+}
+
+func (b *builder) Begin(isMeta bool, brace byte) error {
+	start, end := b.scn.Pos()
+	s := &Seq{Meta: isMeta, Brace: brace}
+	s.Leading = b.gap(start.Offset)
+	b.add(s)
+	b.stack = append(b.stack, s)
+	b.last = end.Offset
+	return nil
+}
+
+func (b *builder) End(isMeta bool, brace byte) error {
+	start, end := b.scn.Pos()
+	l := len(b.stack) - 1
+	s := b.stack[l]
+	b.stack = b.stack[:l]
+	s.End = b.gap(start.Offset)
+	b.last = end.Offset
+	b.prev = &s.Trivia
+	return nil
+}
+
+func (b *builder) Atom(isMeta bool, atom []byte, quoted bool) error {
+	start, end := b.scn.Pos()
+	a := &Atom{Meta: isMeta, Text: string(atom)}
+	switch {
+	case b.scn.IsRaw():
+		a.Style = Raw
+	case quoted:
+		a.Style = Quoted
+	}
+	a.src = string(b.src[start.Offset:end.Offset])
+	a.srcMeta, a.srcText, a.srcStyle = a.Meta, a.Text, a.Style
+	a.Leading = b.gap(start.Offset)
+	b.add(a)
+	b.last = end.Offset
+	b.prev = &a.Trivia
+	return nil
+}
+
+// Parse reads the syntax tree from src. If opts is nil, the default XSX
+// dialect is used.
+func Parse(src []byte, opts *Options) (*File, error) {
+	b := &builder{src: src, file: new(File)}
+	if opts != nil {
+		b.file.opts = *opts
+	}
+	b.scn = xsx.NewHandlerScanner(b)
+	b.scn.Comments = b.file.opts.Comments
+	b.scn.Escapes = b.file.opts.Escapes
+	b.scn.UTF8 = b.file.opts.UTF8
+	b.scn.RawAtoms = b.file.opts.RawAtoms
+	if err := b.scn.Scan(src); err != nil {
+		return nil, err
+	}
+	if err := b.scn.Finish(); err != nil {
+		return nil, err
+	}
+	b.file.End = b.gap(int64(len(src)))
+	return b.file, nil
+}
+
+// ParseString reads the syntax tree from str, see Parse.
+func ParseString(str string, opts *Options) (*File, error) {
+	return Parse([]byte(str), opts)
+}
@@ -0,0 +1,131 @@
+package cst
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"git.fractalqb.de/fractalqb/xsx"
+)
+
+type printer struct {
+	w       io.Writer
+	n       int64
+	esc     bool
+	wasAtom bool // the last token written was an atom without trailing trivia
+	mod     bool // the last atom written was modified
+	err     error
+}
+
+func (p *printer) write(s string) {
+	if p.err != nil || s == "" {
+		return
+	}
+	n, err := io.WriteString(p.w, s)
+	p.n += int64(n)
+	p.err = err
+}
+
+func (p *printer) node(n Node) {
+	switch n := n.(type) {
+	case *Atom:
+		p.atom(n)
+	case *Seq:
+		p.seq(n)
+	default:
+		if p.err == nil {
+			p.err = fmt.Errorf("cst: illegal node type %T", n)
+		}
+	}
+}
+
+func (p *printer) atom(a *Atom) {
+	p.write(a.Leading)
+	txt := a.spelling(p.esc)
+	// separate new or modified atoms from adjacent atoms
+	if p.wasAtom && a.Leading == "" && (p.mod || a.Modified()) {
+		p.write(" ")
+	}
+	p.write(txt)
+	p.wasAtom = a.Trailing == ""
+	p.mod = a.Modified()
+	p.write(a.Trailing)
+}
+
+func (p *printer) seq(s *Seq) {
+	p.write(s.Leading)
+	if s.Meta {
+		p.write(xsx.MetaStr)
+	}
+	brace := s.Brace
+	switch brace {
+	case '(', '[', '{':
+	case 0:
+		brace = '('
+	default:
+		if p.err == nil {
+			p.err = fmt.Errorf("cst: illegal brace '%c'", brace)
+		}
+		return
+	}
+	p.write(string(brace))
+	p.wasAtom = false
+	for _, e := range s.Elems {
+		p.node(e)
+	}
+	p.write(s.End)
+	switch brace {
+	case '(':
+		p.write(")")
+	case '[':
+		p.write("]")
+	default:
+		p.write("}")
+	}
+	p.wasAtom = false
+	p.write(s.Trailing)
+}
+
+// spelling returns the atom as it is written. With esc, modified atoms are
+// quoted with the extended escape dialect.
+func (a *Atom) spelling(esc bool) string {
+	if !a.Modified() {
+		return a.src
+	}
+	var sb strings.Builder
+	if a.Meta {
+		sb.WriteString(xsx.MetaStr)
+	}
+	style := a.Style
+	if style == Plain && (xsx.NeedQuote(a.Text) || esc && xsx.NeedQuoteExt(a.Text)) {
+		style = Quoted
+	}
+	switch {
+	case style == Raw:
+		xsx.RawQuoteTo(a.Text, &sb)
+	case style == Plain:
+		sb.WriteString(a.Text)
+	case esc:
+		xsx.QuoteExtTo(a.Text, &sb)
+	default:
+		xsx.QuoteTo(a.Text, &sb)
+	}
+	return sb.String()
+}
+
+// WriteTo writes f to w. An unmodified File is written exactly as it was
+// read.
+func (f *File) WriteTo(w io.Writer) (int64, error) {
+	p := printer{w: w, esc: f.opts.Escapes}
+	for _, n := range f.Nodes {
+		p.node(n)
+	}
+	p.write(f.End)
+	return p.n, p.err
+}
+
+func (f *File) String() string {
+	var sb strings.Builder
+	f.WriteTo(&sb)
+	return sb.String()
+}
@@ -0,0 +1,92 @@
+package xsx
+
+import (
+	"errors"
+	"fmt"
+	"unicode/utf8"
+)
+
+// maxEscapeLen is the length of the longest escape sequence of the extended
+// escape dialect that decodes to at least one byte, e.g. '\u{000041}'.
+const maxEscapeLen = 10
+
+// unescape appends the decoded form of the escaped quoted atom src to dst.
+// On error, at is the offset in src of the illegal escape sequence.
+func unescape(dst, src []byte) (res []byte, at int, err error) {
+	for i := 0; i < len(src); i++ {
+		c := src[i]
+		if c != '\\' {
+			dst = append(dst, c)
+			continue
+		}
+		at = i
+		if i++; i == len(src) {
+			return dst, at, errors.New("incomplete escape sequence")
+		}
+		switch c = src[i]; c {
+		case '"', '\\':
+			dst = append(dst, c)
+		case 'n':
+			dst = append(dst, '\n')
+		case 't':
+			dst = append(dst, '\t')
+		case 'r':
+			dst = append(dst, '\r')
+		case '0':
+			dst = append(dst, 0)
+		case 'x':
+			if i+2 >= len(src) || !isHex(src[i+1]) || !isHex(src[i+2]) {
+				return dst, at, errors.New(`\x needs two hex digits`)
+			}
+			dst = append(dst, hexVal(src[i+1])<<4|hexVal(src[i+2]))
+			i += 2
+		case 'u':
+			var r rune
+			if i++; i == len(src) || src[i] != '{' {
+				return dst, at, errors.New(`\u needs hex digits in braces`)
+			}
+			n := 0
+			for i++; i < len(src) && isHex(src[i]); i++ {
+				r = r<<4 | rune(hexVal(src[i]))
+				n++
+			}
+			if n == 0 || n > 6 || i == len(src) || src[i] != '}' {
+				return dst, at, errors.New(`\u needs 1 to 6 hex digits in braces`)
+			}
+			if !utf8.ValidRune(r) {
+				return dst, at, fmt.Errorf("invalid code point U+%X", r)
+			}
+			dst = utf8.AppendRune(dst, r)
+		default:
+			r, _ := utf8.DecodeRune(src[i:])
+			return dst, at, fmt.Errorf(`unknown escape '\%c'`, r)
+		}
+	}
+	return dst, 0, nil
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func hexVal(c byte) byte {
+	switch {
+	case c <= '9':
+		return c - '0'
+	case c >= 'a':
+		return c - 'a' + 10
+	}
+	return c - 'A' + 10
+}
+
+// escapeError returns a ScanError of kind ScanBadEscape for the illegal
+// escape at offset at of the escaped quoted atom that starts at s.tokStart.
+func (s *Scanner) escapeError(txt []byte, meta bool, atom []byte, at int, err error) error {
+	pos := s.resolve(txt, s.tokStart)
+	if meta {
+		pos.advance(metaAtom)
+	}
+	pos.advance([]byte{'"'})
+	pos.advance(atom[:at])
+	return s.scanError(ScanBadEscape, pos, txt, "illegal escape sequence", err)
+}
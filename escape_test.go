@@ -0,0 +1,117 @@
+package xsx
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stvp/assert"
+)
+
+func escapeScanner(wr *bytes.Buffer) *Scanner {
+	s := NewScanner(
+		BeginNop,
+		EndNop,
+		func(meta bool, atom []byte, quoted bool) { fmt.Fprintf(wr, "%t %q\n", quoted, atom) },
+	)
+	s.Escapes = true
+	return s
+}
+
+func ExampleEscapeExtTo() {
+	var sb strings.Builder
+	QuoteExtTo("line 1\n\tline \"2\"\x00\x7f\u00a0\xff", &sb)
+	fmt.Println(sb.String())
+	// Output:
+	// "line 1\n\tline \"2\"\0\x7f\u{a0}\xff"
+}
+
+func TestScanner_Escapes(t *testing.T) {
+	var out bytes.Buffer
+	s := escapeScanner(&out)
+	err := s.ScanString(`"a\nb\t\r\0\"\\" \"\x41\xfF\u{1F600}\u{e9}"`)
+	assert.Nil(t, err)
+	assert.Equal(t, "true \"a\\nb\\t\\r\\x00\\\"\\\\\"\n"+
+		"true \"A\\xff😀é\"\n",
+		out.String())
+
+	out.Reset()
+	s = escapeScanner(&out)
+	s.Escapes = false
+	assert.Nil(t, s.ScanString(`"a\nb"`))
+	assert.Equal(t, "true \"anb\"\n", out.String())
+}
+
+func TestScanner_EscapesSplit(t *testing.T) {
+	checkSplitInvariant(t, escapeScanner, `(a "x\u{1F600}y\\\"z" \"\x41\n" "")`)
+	for _, tc := range []struct {
+		in     string
+		maxLen int
+	}{
+		{`"\x41\x42"`, 3},
+		{`"\u{000041}\u{42}"`, 2},
+	} {
+		checkSplitInvariant(t, func(wr *bytes.Buffer) *Scanner {
+			s := escapeScanner(wr)
+			s.MaxAtomLen = tc.maxLen
+			return s
+		}, tc.in)
+	}
+}
+
+func TestScanner_BadEscape(t *testing.T) {
+	for _, tc := range []struct {
+		in        string
+		line, col int
+	}{
+		{`"\q"`, 1, 2},
+		{"(a\n  \\\"xy\\x4\")", 2, 7},
+		{`"\u{110000}"`, 1, 2},
+		{`"\u{}"`, 1, 2},
+		{`"\u41"`, 1, 2},
+		{"\"ä\n\\ä\"", 2, 1},
+	} {
+		for split := 0; split < len(tc.in); split++ {
+			var out bytes.Buffer
+			s := escapeScanner(&out)
+			err := s.Scan([]byte(tc.in[:split]))
+			if err == nil {
+				err = s.Scan([]byte(tc.in[split:]))
+			}
+			var serr *ScanError
+			if !errors.As(err, &serr) {
+				t.Fatalf("%q split %d: no scan error: %v", tc.in, split, err)
+			}
+			assert.True(t, errors.Is(err, ScanBadEscape), err)
+			assert.Equal(t, tc.line, serr.Line(), tc.in)
+			assert.Equal(t, tc.col, serr.Column(), tc.in)
+		}
+	}
+}
+
+func TestPrinter_Escapes(t *testing.T) {
+	atoms := []string{"", "plain", "two\nlines", "tab\there", "\x00\x01\u0085\u2028", "bad\xc3(", `q"\`}
+	var sb strings.Builder
+	p := Compact(&sb)
+	p.Escapes = true
+	assert.Nil(t, p.Begin('(', false))
+	for _, a := range atoms {
+		assert.Nil(t, p.Atom(a, false, Qcond))
+	}
+	assert.Nil(t, p.End())
+	assert.False(t, strings.ContainsAny(sb.String(), "\n\t\r\x00"), sb.String())
+
+	pp := NewPullParser(strings.NewReader(sb.String()))
+	pp.Escapes = true
+	tok, err := pp.Next()
+	assert.Nil(t, err)
+	assert.Equal(t, TokBegin, tok)
+	for _, a := range atoms {
+		tok, err = pp.Next()
+		assert.Nil(t, err)
+		assert.Equal(t, TokAtom, tok)
+		assert.Equal(t, a, pp.Atom)
+	}
+}
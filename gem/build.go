@@ -0,0 +1,89 @@
+package gem
+
+import (
+	"fmt"
+
+	"git.fractalqb.de/fractalqb/xsx"
+)
+
+// Builder is an xsx.Printer that builds gem expressions. Like State it
+// collects each top-level expression in Results. This lets everything that
+// writes to an xsx.Printer, e.g. xsx.Write or xsx.Marshaler, build
+// expressions without parsing printed text.
+//
+// The quote mode of an atom is kept as far as the Atom can represent it:
+// xsx.Qforce makes a quoted atom, xsx.Qraw a raw atom and xsx.QcondRaw a raw
+// atom if a printer without escapes would write it raw, see xsx.PreferRaw.
+// As Print quotes atoms when needed, xsx.Qcond and xsx.QSUPPRESS make plain
+// atoms.
+type Builder struct {
+	Results []Expr
+	ctx     []*Sequence
+}
+
+func (b *Builder) add(x Expr) {
+	if len(b.ctx) == 0 {
+		b.Results = append(b.Results, x)
+	} else {
+		s := b.ctx[len(b.ctx)-1]
+		s.Elems = append(s.Elems, x)
+	}
+}
+
+func (b *Builder) Begin(bracket rune, meta bool) error {
+	switch bracket {
+	case '(', '[', '{':
+	default:
+		return fmt.Errorf("gem build: illegal opening bracket '%c'", bracket)
+	}
+	s := &Sequence{}
+	s.SetMeta(meta)
+	s.SetBrace(FromRune(byte(bracket)))
+	b.add(s)
+	b.ctx = append(b.ctx, s)
+	return nil
+}
+
+func (b *Builder) End() error {
+	if len(b.ctx) == 0 {
+		return xsx.ErrNothingToEnd
+	}
+	b.ctx = b.ctx[:len(b.ctx)-1]
+	return nil
+}
+
+func (b *Builder) Atom(atom string, meta bool, quote xsx.QuoteMode) error {
+	a := &Atom{Str: atom}
+	a.SetMeta(meta)
+	switch quote {
+	case xsx.Qforce:
+		a.SetQuoted(true)
+	case xsx.Qraw:
+		a.SetRaw(true)
+	case xsx.QcondRaw:
+		a.SetRaw(xsx.PreferRaw(atom, false))
+	}
+	b.add(a)
+	return nil
+}
+
+// Newline is ignored because gem expressions do not keep formatting.
+func (b *Builder) Newline(count int, indent int) error { return nil }
+
+// Depth returns the number of open sequences.
+func (b *Builder) Depth() int { return len(b.ctx) }
+
+// Close checks that all sequences were ended. Results holds unfinished
+// expressions as far as they were built.
+func (b *Builder) Close() error {
+	if l := len(b.ctx); l > 0 {
+		return fmt.Errorf("gem build: %w: depth %d", xsx.ErrOpenSequence, l)
+	}
+	return nil
+}
+
+// Reset drops all Results and open sequences so that b can be reused.
+func (b *Builder) Reset() {
+	b.Results = nil
+	b.ctx = b.ctx[:0]
+}
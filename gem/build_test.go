@@ -0,0 +1,102 @@
+package gem
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"git.fractalqb.de/fractalqb/xsx"
+	"github.com/stvp/assert"
+)
+
+func ExampleBuilder() {
+	var b Builder
+	err := xsx.Write(&b,
+		xsx.B('('), "foo", xsx.Bm('['), "bar", xsx.End,
+	)
+	if err == nil {
+		err = b.Atom("baz", false, xsx.Qforce)
+	}
+	if err == nil {
+		err = b.End()
+	}
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	seq := b.Results[0].(*Sequence)
+	fmt.Println(seq.Brace(), seq.Elems[1].Meta(), seq.Elems[2].(*Atom).Quoted())
+	Print(xsx.Compact(os.Stdout), seq)
+	// Output:
+	// Paren true true
+	// (foo\[bar]"baz")
+}
+
+func TestBuilder_roundTrip(t *testing.T) {
+	const doc = `(a "b" \c "d\"e") [] \{x (y)} "top"`
+	exprs, err := ParseString(doc, nil)
+	assert.Nil(t, err)
+	var b Builder
+	for _, x := range exprs {
+		assert.Nil(t, Print(&b, x))
+	}
+	assert.Nil(t, b.Close())
+	assert.Equal(t, len(exprs), len(b.Results))
+	var sb strings.Builder
+	pr := xsx.Compact(&sb)
+	for _, x := range b.Results {
+		assert.Nil(t, Print(pr, x))
+	}
+	assert.Equal(t, `(a "b" \c "d\"e")[]\{x(y)}"top"`, sb.String())
+}
+
+func TestBuilder_quoteModes(t *testing.T) {
+	var b Builder
+	b.Atom("a", false, xsx.Qcond)
+	b.Atom("b", true, xsx.Qforce)
+	b.Atom("c", false, xsx.Qraw)
+	b.Atom("d", false, xsx.QcondRaw)
+	b.Atom(`e"`, false, xsx.QcondRaw)
+	b.Atom("f\\\n", false, xsx.QcondRaw)
+	type flags struct{ meta, quoted, raw bool }
+	var got []flags
+	for _, x := range b.Results {
+		a := x.(*Atom)
+		got = append(got, flags{a.Meta(), a.Quoted(), a.Raw()})
+	}
+	assert.Equal(t, []flags{
+		{false, false, false},
+		{true, true, false},
+		{false, false, true},
+		{false, false, false},
+		{false, false, true},
+		{false, false, true},
+	}, got)
+	var sb strings.Builder
+	pr := xsx.Compact(&sb)
+	for _, x := range b.Results[3:] {
+		a := x.(*Atom)
+		assert.Nil(t, pr.Atom(a.Str, false, xsx.QcondRaw))
+		assert.Nil(t, Print(pr, x))
+	}
+	assert.Equal(t, "d d #\"e\"\"# #\"e\"\"# #\"f\\\n\"# #\"f\\\n\"#", sb.String())
+}
+
+func TestBuilder_errors(t *testing.T) {
+	var b Builder
+	assert.True(t, b.Begin('<', false) != nil)
+	assert.Equal(t, xsx.ErrNothingToEnd, b.End())
+	assert.Nil(t, b.Begin('{', false))
+	assert.Nil(t, b.Begin('(', true))
+	assert.Equal(t, 2, b.Depth())
+	err := b.Close()
+	assert.True(t, errors.Is(err, xsx.ErrOpenSequence), err)
+	assert.Nil(t, b.End())
+	assert.Nil(t, b.End())
+	assert.Nil(t, b.Close())
+	assert.Equal(t, 1, len(b.Results))
+	b.Reset()
+	assert.Equal(t, 0, len(b.Results))
+}
@@ -3,6 +3,8 @@ package gem
 
 import (
 	"fmt"
+	"iter"
+	"slices"
 )
 
 type Expr interface {
@@ -15,6 +17,7 @@ type expBase int
 const (
 	maskMeta     = 1
 	maskAtomQuot = 2
+	maskAtomRaw  = 4 // atoms do not use the brace bits of sequences
 )
 
 func (e expBase) Meta() bool {
@@ -46,11 +49,30 @@ func (a *Atom) SetQuoted(flag bool) {
 	}
 }
 
+// Raw reports whether the atom was read from or is to be written as raw atom,
+// see xsx.RawStart.
+func (a *Atom) Raw() bool {
+	return a.expBase&maskAtomRaw != 0
+}
+
+func (a *Atom) SetRaw(flag bool) {
+	if flag {
+		a.expBase |= maskAtomRaw
+	} else {
+		a.expBase &= ^maskAtomRaw
+	}
+}
+
 type Sequence struct {
 	expBase
 	Elems []Expr
 }
 
+// All returns an iterator over the indices and elements of s.
+func (s *Sequence) All() iter.Seq2[int, Expr] {
+	return slices.All(s.Elems)
+}
+
 //go:generate stringer -type Brace
 type Brace int
 
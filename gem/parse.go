@@ -1,7 +1,10 @@
 package gem
 
 import (
+	"io"
 	"strings"
+
+	"git.fractalqb.de/fractalqb/xsx"
 )
 
 type State struct {
@@ -42,3 +45,128 @@ func (pst *State) Atom(isMeta bool, atom []byte, quoted bool) {
 		s.Elems = append(s.Elems, a)
 	}
 }
+
+// Limits restricts the input accepted by ParseBytes, ParseString and
+// ParseReader. Limits that are 0 are not checked. When input exceeds a limit,
+// the parse functions fail with an *xsx.LimitError. Besides the limits of
+// xsx.Limits, its Limit is one of "elements" or "bytes". Limits detected
+// while scanning are reported as reason of an xsx.ScanError. All
+// LimitErrors match xsx.ScanLimit with errors.Is.
+type Limits struct {
+	xsx.Limits
+	// MaxElems is the maximum number of atoms and sequences in total
+	MaxElems int
+	// MaxBytes is the maximum size of the input in bytes
+	MaxBytes int64
+}
+
+// limitState is an xsx.Handler that builds the parse results and checks the
+// limits.
+type limitState struct {
+	State
+	lim   Limits
+	elems int
+}
+
+func (ls *limitState) elem() error {
+	ls.elems++
+	if ls.lim.MaxElems > 0 && ls.elems > ls.lim.MaxElems {
+		return &xsx.LimitError{Limit: "elements", Max: int64(ls.lim.MaxElems)}
+	}
+	return nil
+}
+
+func (ls *limitState) Begin(isMeta bool, brace byte) error {
+	if err := ls.elem(); err != nil {
+		return err
+	}
+	ls.State.Begin(isMeta, brace)
+	return nil
+}
+
+func (ls *limitState) End(isMeta bool, brace byte) error {
+	ls.State.End(isMeta, brace)
+	return nil
+}
+
+func (ls *limitState) Atom(isMeta bool, atom []byte, quoted bool) error {
+	if err := ls.elem(); err != nil {
+		return err
+	}
+	ls.State.Atom(isMeta, atom, quoted)
+	return nil
+}
+
+func newLimitState(lim *Limits) *limitState {
+	res := new(limitState)
+	if lim != nil {
+		res.lim = *lim
+	}
+	return res
+}
+
+// scanner returns a scanner that reports to ls and checks the limits that
+// are checked by xsx.Scanner.
+func (ls *limitState) scanner() *xsx.Scanner {
+	res := xsx.NewHandlerScanner(ls)
+	res.Limits = ls.lim.Limits
+	return res
+}
+
+// ParseBytes parses all expressions from data. If lim is not nil, the input
+// must not exceed the limits.
+func ParseBytes(data []byte, lim *Limits) ([]Expr, error) {
+	ls := newLimitState(lim)
+	if ls.lim.MaxBytes > 0 && int64(len(data)) > ls.lim.MaxBytes {
+		return nil, &xsx.LimitError{Limit: "bytes", Max: ls.lim.MaxBytes}
+	}
+	scn := ls.scanner()
+	if err := scn.Scan(data); err != nil {
+		return nil, err
+	}
+	if err := scn.Finish(); err != nil {
+		return nil, err
+	}
+	return ls.Results, nil
+}
+
+// ParseString parses all expressions from str. If lim is not nil, the input
+// must not exceed the limits.
+func ParseString(str string, lim *Limits) ([]Expr, error) {
+	return ParseBytes([]byte(str), lim)
+}
+
+// ParseReader parses all expressions read from rd. If lim is not nil, the
+// input must not exceed the limits.
+func ParseReader(rd io.Reader, lim *Limits) ([]Expr, error) {
+	ls := newLimitState(lim)
+	if ls.lim.MaxBytes > 0 {
+		rd = &limitReader{rd: rd, n: ls.lim.MaxBytes}
+	}
+	if err := ls.scanner().Read(rd); err != nil {
+		return nil, err
+	}
+	return ls.Results, nil
+}
+
+// limitReader is like io.LimitedReader but fails with a LimitError when more
+// than n bytes are available.
+type limitReader struct {
+	rd io.Reader
+	n  int64
+	m  int64
+}
+
+func (lr *limitReader) Read(p []byte) (n int, err error) {
+	if lr.m > lr.n {
+		return 0, &xsx.LimitError{Limit: "bytes", Max: lr.n}
+	}
+	if int64(len(p)) > lr.n-lr.m+1 {
+		p = p[:lr.n-lr.m+1]
+	}
+	n, err = lr.rd.Read(p)
+	if lr.m += int64(n); lr.m > lr.n {
+		return 0, &xsx.LimitError{Limit: "bytes", Max: lr.n}
+	}
+	return n, err
+}
@@ -1,7 +1,12 @@
 package gem
 
 import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
 	"testing"
+	"testing/iotest"
 
 	"git.fractalqb.de/fractalqb/xsx"
 	"github.com/stvp/assert"
@@ -25,3 +30,68 @@ func TestParse_complex(t *testing.T) {
 	assert.Equal(t, Paren, s.Brace())
 	assert.Equal(t, 2, len(s.Elems))
 }
+
+func ExampleParseString() {
+	exprs, err := ParseString(`foo (bar \[baz]) "quux"`, nil)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	pr := xsx.Compact(os.Stdout)
+	for _, x := range exprs {
+		Print(pr, x)
+		fmt.Println()
+	}
+	// Output:
+	// foo
+	// (bar\[baz])
+	// "quux"
+}
+
+func TestParse_limits(t *testing.T) {
+	for _, tc := range []struct {
+		txt   string
+		lim   Limits
+		limit string
+	}{
+		{"((()))", Limits{Limits: xsx.Limits{MaxDepth: 2}}, "depth"},
+		{"a bcd", Limits{Limits: xsx.Limits{MaxAtomLen: 2}}, "atom length"},
+		{`"abc"`, Limits{Limits: xsx.Limits{MaxAtomLen: 2}}, "atom length"},
+		{"a (b c)", Limits{MaxElems: 3}, "elements"},
+		{"a (b c)", Limits{MaxBytes: 6}, "bytes"},
+	} {
+		for name, parse := range map[string]func(string, *Limits) ([]Expr, error){
+			"string": ParseString,
+			"reader": func(s string, lim *Limits) ([]Expr, error) {
+				return ParseReader(iotest.OneByteReader(strings.NewReader(s)), lim)
+			},
+		} {
+			lim := tc.lim
+			_, err := parse(tc.txt, &lim)
+			var limErr *xsx.LimitError
+			if !errors.As(err, &limErr) {
+				t.Errorf("%s '%s': no limit error: %v", name, tc.txt, err)
+			} else if limErr.Limit != tc.limit {
+				t.Errorf("%s '%s': wrong limit %s", name, tc.txt, limErr.Limit)
+			}
+			res, err := parse(tc.txt, nil)
+			assert.Nil(t, err, name, tc.txt)
+			assert.True(t, len(res) > 0, name, tc.txt)
+		}
+	}
+}
+
+func TestParseReader_errors(t *testing.T) {
+	_, err := ParseReader(strings.NewReader("(foo"), nil)
+	assert.True(t, errors.Is(err, xsx.ScanTruncated), err)
+	res, err := ParseReader(strings.NewReader("a (b) c"), &Limits{MaxBytes: 7})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(res))
+}
+
+func TestParse_scanLimits(t *testing.T) {
+	_, err := ParseString(`(a \(b (c)))`, &Limits{Limits: xsx.Limits{MaxMetaDepth: 1}})
+	assert.True(t, errors.Is(err, xsx.ScanLimit), err)
+	_, err = ParseString(`a b c`, &Limits{MaxElems: 2})
+	assert.True(t, errors.Is(err, xsx.ScanLimit), err)
+}
@@ -1,32 +1,74 @@
 package gem
 
 import (
+	"fmt"
+
 	"git.fractalqb.de/fractalqb/xsx"
 )
 
 func Print(pr xsx.Printer, xpr Expr) (err error) {
 	switch expr := xpr.(type) {
 	case *Atom:
-		if expr.Quoted() {
+		switch {
+		case expr.Raw():
+			err = pr.Atom(expr.Str, expr.Meta(), xsx.Qraw)
+		case expr.Quoted():
 			err = pr.Atom(expr.Str, expr.Meta(), xsx.Qforce)
-		} else {
+		default:
 			err = pr.Atom(expr.Str, expr.Meta(), xsx.Qcond)
 		}
 	case *Sequence:
 		switch expr.Brace() {
 		case Paren:
-			pr.Begin('(', expr.Meta())
+			err = pr.Begin('(', expr.Meta())
 		case Square:
-			pr.Begin('[', expr.Meta())
+			err = pr.Begin('[', expr.Meta())
 		case Curly:
-			pr.Begin('{', expr.Meta())
+			err = pr.Begin('{', expr.Meta())
 		default:
-			pr.Begin('(', expr.Meta()) // be forgiving
+			err = pr.Begin('(', expr.Meta()) // be forgiving
+		}
+		if err != nil {
+			return err
 		}
 		for _, sub := range expr.Elems {
-			Print(pr, sub)
+			if err = Print(pr, sub); err != nil {
+				return err
+			}
 		}
-		pr.End()
+		err = pr.End()
 	}
 	return err
 }
+
+// MarshalXSX implements xsx.Marshaler.
+func (a *Atom) MarshalXSX(pr xsx.Printer) error { return Print(pr, a) }
+
+// MarshalXSX implements xsx.Marshaler.
+func (s *Sequence) MarshalXSX(pr xsx.Printer) error { return Print(pr, s) }
+
+// UnmarshalXSX implements xsx.Unmarshaler.
+func (a *Atom) UnmarshalXSX(p *xsx.PullParser) error {
+	x, err := ReadCurrent(p)
+	if err != nil {
+		return err
+	}
+	if ax, ok := x.(*Atom); ok {
+		*a = *ax
+		return nil
+	}
+	return fmt.Errorf("gem unmarshal: cannot read sequence into atom")
+}
+
+// UnmarshalXSX implements xsx.Unmarshaler.
+func (s *Sequence) UnmarshalXSX(p *xsx.PullParser) error {
+	x, err := ReadCurrent(p)
+	if err != nil {
+		return err
+	}
+	if sx, ok := x.(*Sequence); ok {
+		*s = *sx
+		return nil
+	}
+	return fmt.Errorf("gem unmarshal: cannot read atom into sequence")
+}
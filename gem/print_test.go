@@ -1,7 +1,9 @@
 package gem
 
 import (
+	"fmt"
 	"os"
+	"strings"
 
 	"git.fractalqb.de/fractalqb/xsx"
 )
@@ -45,3 +47,68 @@ func ExamplePrint() {
 	// Output:
 	// ["foo"\(bar)baz]
 }
+
+func ExampleSequence_MarshalXSX() {
+	var doc struct {
+		Name string
+		Spec Sequence
+	}
+	err := xsx.Unmarshal([]byte(`{Name foo Spec (bar \[baz "quux"])}`), &doc)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	doc.Name = "renamed"
+	data, err := xsx.Marshal(doc)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(string(data))
+	// Output:
+	// {Name renamed Spec(bar\[baz "quux"])}
+}
+
+func ExampleAtom_Raw() {
+	p := xsx.NewPullParser(strings.NewReader(`(grep #"\d+ "items""# "a b")`))
+	p.RawAtoms = true
+	expr, err := ReadNext(p)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(expr.(*Sequence).Elems[1].(*Atom).Raw())
+	Print(xsx.Compact(os.Stdout), expr)
+	// Output:
+	// true
+	// (grep #"\d+ "items""# "a b")
+}
+
+func ExamplePrint_layout() {
+	exprs, err := ParseString(`(server main (listen localhost 8080) (log \(level debug)))`, nil)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	Print(xsx.Layout(os.Stdout, 30, "  "), exprs[0])
+	// Output:
+	// (server main
+	//   (listen localhost 8080)
+	//   (log \(level debug)))
+}
+
+func ExamplePrint_write() {
+	exprs, err := ParseString(`(listen [localhost 8080])`, nil)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	err = xsx.Write(xsx.Compact(os.Stdout),
+		xsx.B('('), "config", exprs[0], xsx.Mv{V: exprs[0]}, exprs, xsx.End,
+	)
+	if err != nil {
+		fmt.Println(err)
+	}
+	// Output:
+	// (config(listen[localhost 8080])\(listen[localhost 8080])[(listen[localhost 8080])])
+}
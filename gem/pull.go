@@ -2,18 +2,29 @@ package gem
 
 import (
 	"fmt"
+	"iter"
 
 	"git.fractalqb.de/fractalqb/xsx"
 )
 
+// ReadCurrent reads the expression that starts with the last token pulled
+// from p. Atoms take their string from p.Atom, i.e. one can set p.Intern,
+// e.g. to an xsx.Interner, to not allocate a string for each repeated atom.
+// If p.Intern does not set p.Atom, e.g. with xsx.InternNone, atoms are
+// converted from p.AtomBytes.
 func ReadCurrent(p *xsx.PullParser) (Expr, error) {
 	switch p.LastToken() {
 	case xsx.TokEOI:
 		return nil, xsx.PullEOI
 	case xsx.TokAtom:
-		a := &Atom{Str: p.Atom}
+		str := p.Atom
+		if str == "" {
+			str = string(p.AtomBytes())
+		}
+		a := &Atom{Str: str}
 		a.SetMeta(p.WasMeta())
 		a.SetQuoted(p.WasQuot)
+		a.SetRaw(p.WasRaw())
 		return a, nil
 	case xsx.TokBegin:
 		return readSeq(p)
@@ -23,12 +34,20 @@ func ReadCurrent(p *xsx.PullParser) (Expr, error) {
 	}
 }
 
+// ReadNext reads the next expression from p. Comments before the expression
+// are skipped.
 func ReadNext(p *xsx.PullParser) (Expr, error) {
-	if tok, _ := p.Next(); tok != xsx.TokEOI {
-		return ReadCurrent(p)
-	} else {
+	tok, err := p.Next()
+	for err == nil && tok == xsx.TokComment {
+		tok, err = p.Next()
+	}
+	switch {
+	case err != nil:
+		return nil, err
+	case tok == xsx.TokEOI:
 		return nil, xsx.PullEOI
 	}
+	return ReadCurrent(p)
 }
 
 func readSeq(p *xsx.PullParser) (Expr, error) {
@@ -44,9 +63,14 @@ func readSeq(p *xsx.PullParser) (Expr, error) {
 	default:
 		panic(fmt.Sprintf("gem pull: illegal opening brace '%c'", p.LastBrace()))
 	}
-	for tok, err := p.Next(); tok != xsx.TokEnd; tok, err = p.Next() {
-		if err != nil {
+	for tok, err := range p.Tokens() {
+		switch {
+		case err != nil:
 			return res, err
+		case tok == xsx.TokEnd:
+			return res, nil
+		case tok == xsx.TokComment:
+			continue
 		}
 		elm, err := ReadCurrent(p)
 		if err != nil {
@@ -54,5 +78,26 @@ func readSeq(p *xsx.PullParser) (Expr, error) {
 		}
 		res.Elems = append(res.Elems, elm)
 	}
-	return res, nil
+	return res, xsx.PullTruncated
+}
+
+// All returns an iterator over all top-level expressions read from p. The
+// iteration ends cleanly at the end of input. If reading fails, the error is
+// yielded with the partially read expression, if any, and iteration ends.
+func All(p *xsx.PullParser) iter.Seq2[Expr, error] {
+	return func(yield func(Expr, error) bool) {
+		for {
+			x, err := ReadNext(p)
+			switch {
+			case err == xsx.PullEOI:
+				return
+			case err != nil:
+				yield(x, err)
+				return
+			}
+			if !yield(x, nil) {
+				return
+			}
+		}
+	}
 }
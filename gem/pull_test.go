@@ -3,6 +3,8 @@ package gem
 import (
 	"bufio"
 	"bytes"
+	"fmt"
+	"strings"
 	"testing"
 
 	"git.fractalqb.de/fractalqb/xsx"
@@ -32,3 +34,70 @@ func TestPullNext_sequence(t *testing.T) {
 	assert.Equal(t, Paren, seq.Brace())
 	assert.Equal(t, 3, len(seq.Elems))
 }
+
+func ExampleAll() {
+	p := xsx.NewPullParser(strings.NewReader(`foo (bar [baz]) \quux`))
+	for x, err := range All(p) {
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		switch x := x.(type) {
+		case *Atom:
+			fmt.Println("atom", x.Str, x.Meta())
+		case *Sequence:
+			fmt.Printf("sequence %c with %d elements\n", x.Brace().Opening(), len(x.Elems))
+			for i, e := range x.All() {
+				fmt.Printf("- %d: %T\n", i, e)
+			}
+		}
+	}
+	// Output:
+	// atom foo false
+	// sequence ( with 2 elements
+	// - 0: *gem.Atom
+	// - 1: *gem.Sequence
+	// atom quux true
+}
+
+func TestAll_error(t *testing.T) {
+	for txt, good := range map[string]int{"a (b": 1, "a b)": 2, "a (b]": 1} {
+		p := xsx.NewPullParser(strings.NewReader(txt))
+		var n int
+		var err error
+		for _, e := range All(p) {
+			if e != nil {
+				err = e
+			} else {
+				n++
+			}
+		}
+		assert.Equal(t, good, n, txt)
+		assert.True(t, err != nil, txt)
+	}
+}
+
+func TestReadNext_comments(t *testing.T) {
+	p := xsx.NewPullParser(strings.NewReader("; head\n(a ;(note) b) ;[tail]"))
+	p.Comments = true
+	p.PullComments = true
+	var n int
+	for x, err := range All(p) {
+		assert.Nil(t, err)
+		seq, ok := x.(*Sequence)
+		assert.True(t, ok)
+		assert.Equal(t, 2, len(seq.Elems))
+		n++
+	}
+	assert.Equal(t, 1, n)
+}
+
+func TestReadCurrent_internNone(t *testing.T) {
+	p := xsx.NewPullParser(strings.NewReader(`(a "" b)`))
+	p.Intern = xsx.InternNone
+	x, err := ReadNext(p)
+	assert.Nil(t, err)
+	var sb strings.Builder
+	assert.Nil(t, Print(xsx.Compact(&sb), x))
+	assert.Equal(t, `(a "" b)`, sb.String())
+}
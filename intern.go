@@ -0,0 +1,50 @@
+package xsx
+
+// Interner converts atoms to strings such that equal atoms share the same
+// string. Its Intern method can be used as PullParser.Intern to avoid heap
+// allocations for atoms that occur repeatedly.
+type Interner struct {
+	// Max limits the number of strings kept by the Interner. When the limit
+	// is reached, new atoms are still converted but not remembered. Max <= 0
+	// means DefaultInternMax, i.e. the memory used by an Interner is always
+	// bounded, even for untrusted input.
+	Max  int
+	strs map[string]string
+}
+
+// DefaultInternMax is the number of strings kept by an Interner without Max.
+const DefaultInternMax = 1 << 14
+
+func NewInterner(max int) *Interner {
+	return &Interner{Max: max, strs: make(map[string]string)}
+}
+
+// Intern returns the string for atom. The lookup of atoms that are already
+// known does not allocate.
+func (in *Interner) Intern(atom []byte) string {
+	if s, ok := in.strs[string(atom)]; ok {
+		return s
+	}
+	s := string(atom)
+	if in.strs == nil {
+		in.strs = make(map[string]string)
+	}
+	max := in.Max
+	if max <= 0 {
+		max = DefaultInternMax
+	}
+	if len(in.strs) < max {
+		in.strs[s] = s
+	}
+	return s
+}
+
+// Len returns the number of strings kept by the Interner.
+func (in *Interner) Len() int { return len(in.strs) }
+
+// Reset forgets all strings of the Interner.
+func (in *Interner) Reset() {
+	for k := range in.strs {
+		delete(in.strs, k)
+	}
+}
@@ -0,0 +1,34 @@
+package xsx
+
+import "fmt"
+
+// Limits restricts the input accepted by a Scanner, e.g. to safely scan
+// untrusted input. Limits are checked while scanning, i.e. the Scanner fails
+// as soon as input exceeds a limit. Limits that are 0 are not checked.
+type Limits struct {
+	// MaxDepth is the maximum nesting depth of sequences.
+	MaxDepth int
+	// MaxAtomLen is the maximum length of an atom in bytes. For quoted atoms
+	// it is the length of the atom after unescaping.
+	MaxAtomLen int
+	// MaxMetaDepth is the maximum nesting depth of sequences within a meta
+	// sequence. The outermost meta sequence has meta depth 1.
+	MaxMetaDepth int
+}
+
+// LimitError describes the limit that was exceeded by input. Scanners report
+// a LimitError as reason of a ScanError of kind ScanLimit. LimitError itself
+// also matches ScanLimit with errors.Is.
+type LimitError struct {
+	// Limit is the name of the exceeded limit, e.g. "depth", "meta depth" or
+	// "atom length"
+	Limit string
+	Max   int64
+}
+
+func (err *LimitError) Error() string {
+	return fmt.Sprintf("%s exceeds limit %d", err.Limit, err.Max)
+}
+
+// Is makes LimitError match ScanLimit with errors.Is.
+func (err *LimitError) Is(target error) bool { return target == ScanLimit }
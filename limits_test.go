@@ -0,0 +1,87 @@
+package xsx
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestScanner_limits(t *testing.T) {
+	for _, tc := range []struct {
+		txt   string
+		lim   Limits
+		limit string
+		line  int
+		col   int
+	}{
+		{"(a [b {c}])", Limits{MaxDepth: 2}, "depth", 1, 7},
+		{"(a \\[b {c}])", Limits{MaxDepth: 2}, "depth", 1, 8},
+		{"(a \\[b {c}])", Limits{MaxMetaDepth: 1}, "meta depth", 1, 8},
+		{"(a \\[b] \\{c})", Limits{MaxMetaDepth: 1}, "", 0, 0},
+		{"((\\[b {c}]))", Limits{MaxMetaDepth: 2}, "", 0, 0},
+		{"(foo\n  barbaz)", Limits{MaxAtomLen: 5}, "atom length", 2, 3},
+		{`(foo "b\"rbaz")`, Limits{MaxAtomLen: 5}, "atom length", 1, 6},
+		{`(foo "b\"rbaz")`, Limits{MaxAtomLen: 6}, "", 0, 0},
+	} {
+		for split := 0; split <= len(tc.txt); split++ {
+			scn := NewScanner(BeginNop, EndNop, AtomNop)
+			scn.Limits = tc.lim
+			err := scn.Scan([]byte(tc.txt[:split]))
+			if err == nil {
+				err = scn.Scan([]byte(tc.txt[split:]))
+			}
+			if err == nil {
+				err = scn.Finish()
+			}
+			if tc.limit == "" {
+				if err != nil {
+					t.Errorf("'%s' split %d: unexpected error %s", tc.txt, split, err)
+				}
+				continue
+			}
+			if !errors.Is(err, ScanLimit) {
+				t.Fatalf("'%s' split %d: expected limit error, got %v", tc.txt, split, err)
+			}
+			var limErr *LimitError
+			if !errors.As(err, &limErr) || limErr.Limit != tc.limit {
+				t.Errorf("'%s' split %d: wrong reason %v", tc.txt, split, err)
+			}
+			scnErr := err.(*ScanError)
+			if scnErr.Line() != tc.line || scnErr.Column() != tc.col {
+				t.Errorf("'%s' split %d: wrong position %s", tc.txt, split, scnErr.Where())
+			}
+		}
+	}
+}
+
+func TestScanner_atomLimitIncremental(t *testing.T) {
+	scn := NewScanner(BeginNop, EndNop, AtomNop)
+	scn.MaxAtomLen = 100
+	chunk := []byte(strings.Repeat("x", 30))
+	if err := scn.Scan([]byte(`"`)); err != nil {
+		t.Fatal(err)
+	}
+	var err error
+	n := 0
+	for ; err == nil && n < 10; n++ {
+		err = scn.Scan(chunk)
+	}
+	if !errors.Is(err, ScanLimit) {
+		t.Fatalf("expected limit error, got %v", err)
+	}
+	if n != 4 {
+		t.Errorf("limit detected after %d chunks", n)
+	}
+}
+
+func TestPullParser_limits(t *testing.T) {
+	pp := NewPullParserSize(bytes.NewReader([]byte("(a (b (c)))")), 3)
+	pp.MaxDepth = 2
+	var err error
+	for tok := TokBegin; err == nil && tok != TokEOI; tok, err = pp.Next() {
+	}
+	if !errors.Is(err, ScanLimit) {
+		t.Errorf("expected limit error, got %v", err)
+	}
+}
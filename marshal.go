@@ -0,0 +1,480 @@
+package xsx
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/base64"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unsafe"
+)
+
+// Marshal returns the XSX encoding of v. Marshal maps Go values onto XSX
+// as follows:
+//
+//   - Bools, numbers and strings are atoms.
+//   - []byte is an atom with the standard base64 encoding of the bytes.
+//   - time.Duration is an atom in the format of its String method.
+//   - Slices and arrays are sequences in square brackets.
+//   - Maps are sequences in curly braces of alternating keys and values,
+//     sorted by key. Keys must be strings, integers, bools or implement
+//     encoding.TextMarshaler.
+//   - Structs are sequences in curly braces of alternating field names and
+//     values. Only exported fields are encoded. Fields of embedded structs
+//     without name in the tag are encoded as if they were fields of the
+//     outer struct.
+//   - Values that implement Marshaler write themselves with MarshalXSX.
+//     Otherwise values that implement encoding.TextMarshaler are atoms with
+//     the result of MarshalText.
+//   - Pointers and interfaces encode the value they point to. Nil pointers,
+//     nil interfaces, nil slices and nil maps are encoded as '()'. Note that
+//     this makes empty slices and maps that are written with brace '(' read
+//     back as nil. Values that refer to themselves cannot be encoded and
+//     make Marshal fail with an UnsupportedValueError.
+//
+// The encoding of struct fields can be controlled with the struct tag "xsx"
+// that has a comma-separated list of the field name followed by options:
+//
+//	Field int `xsx:"name,meta,omitempty,brace=["`
+//
+// Name "-" omits the field and an empty name keeps the Go name. Option meta
+// writes the field name as meta atom, omitempty omits the field if it is the
+// zero value of its type or an empty slice or map, and brace=B uses brace B
+// instead of the default brace for a field value that is a sequence.
+func Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := marshal(Compact(&buf), v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Marshaler is implemented by types that write their own XSX representation.
+// MarshalXSX must write exactly one atom or one complete sequence to p.
+type Marshaler interface {
+	MarshalXSX(p Printer) error
+}
+
+// Unmarshaler is implemented by types that read their own XSX
+// representation. UnmarshalXSX is called when the first token of the
+// expression was pulled from p, i.e. with p.LastToken() being TokAtom or
+// TokBegin. If it is TokBegin, UnmarshalXSX must pull all tokens up to and
+// including the matching TokEnd.
+type Unmarshaler interface {
+	UnmarshalXSX(p *PullParser) error
+}
+
+var (
+	marshalerType       = reflect.TypeFor[Marshaler]()
+	textMarshalerType   = reflect.TypeFor[encoding.TextMarshaler]()
+	unmarshalerType     = reflect.TypeFor[Unmarshaler]()
+	textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+	durationType        = reflect.TypeFor[time.Duration]()
+)
+
+// UnsupportedTypeError is returned by Marshal when asked to encode a value of
+// a type that has no XSX encoding.
+type UnsupportedTypeError struct {
+	Type reflect.Type
+}
+
+func (err *UnsupportedTypeError) Error() string {
+	return "xsx marshal: unsupported type " + err.Type.String()
+}
+
+// UnsupportedValueError is returned by Marshal when asked to encode a value
+// that has no XSX encoding, e.g. a value that refers to itself.
+type UnsupportedValueError struct {
+	Value reflect.Value
+	Str   string
+}
+
+func (err *UnsupportedValueError) Error() string {
+	return "xsx marshal: unsupported value: " + err.Str
+}
+
+func marshal(p Printer, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.IsValid() && !rv.CanAddr() {
+		// make Marshaler with pointer receivers work for values
+		cp := reflect.New(rv.Type()).Elem()
+		cp.Set(rv)
+		rv = cp
+	}
+	e := encodeState{p: p}
+	return e.value(rv, 0)
+}
+
+type encodeState struct {
+	p       Printer
+	ptrSeen map[ptrKey]struct{} // pointers, maps and slices being encoded
+}
+
+type ptrKey struct {
+	ptr unsafe.Pointer
+	typ reflect.Type
+	len int
+}
+
+// enter marks the pointer, map or slice v as being encoded. It fails if v is
+// already being encoded, i.e. if v refers to itself. If enter succeeds,
+// leave must be called when the encoding of v is done.
+func (e *encodeState) enter(v reflect.Value) (ptrKey, error) {
+	k := ptrKey{ptr: v.UnsafePointer(), typ: v.Type()}
+	if v.Kind() == reflect.Slice {
+		k.len = v.Len()
+	}
+	if _, ok := e.ptrSeen[k]; ok {
+		return k, &UnsupportedValueError{
+			Value: v,
+			Str:   "encountered a cycle via " + v.Type().String(),
+		}
+	}
+	if e.ptrSeen == nil {
+		e.ptrSeen = make(map[ptrKey]struct{})
+	}
+	e.ptrSeen[k] = struct{}{}
+	return k, nil
+}
+
+func (e *encodeState) leave(k ptrKey) { delete(e.ptrSeen, k) }
+
+// elem encodes an element of a slice, array or map.
+func (e *encodeState) elem(v reflect.Value) error { return e.value(v, 0) }
+
+func marshalNil(p Printer) error {
+	if err := p.Begin('(', false); err != nil {
+		return err
+	}
+	return p.End()
+}
+
+// value encodes v. If brace is not 0, it replaces the default brace when v
+// is encoded as sequence.
+func (e *encodeState) value(v reflect.Value, brace rune) (err error) {
+	p := e.p
+	if !v.IsValid() {
+		return marshalNil(p)
+	}
+	if (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) && v.IsNil() {
+		return marshalNil(p)
+	}
+	switch t := v.Type(); {
+	case t.Implements(marshalerType):
+		return v.Interface().(Marshaler).MarshalXSX(p)
+	case t.Kind() != reflect.Pointer && v.CanAddr() &&
+		reflect.PointerTo(t).Implements(marshalerType):
+		return v.Addr().Interface().(Marshaler).MarshalXSX(p)
+	case t.Implements(textMarshalerType):
+		return marshalText(p, v.Interface().(encoding.TextMarshaler))
+	case t.Kind() != reflect.Pointer && v.CanAddr() &&
+		reflect.PointerTo(t).Implements(textMarshalerType):
+		return marshalText(p, v.Addr().Interface().(encoding.TextMarshaler))
+	}
+	if v.Type() == durationType {
+		return p.Atom(time.Duration(v.Int()).String(), false, Qcond)
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		return p.Atom(strconv.FormatBool(v.Bool()), false, Qcond)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return p.Atom(strconv.FormatInt(v.Int(), 10), false, Qcond)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64, reflect.Uintptr:
+		return p.Atom(strconv.FormatUint(v.Uint(), 10), false, Qcond)
+	case reflect.Float32, reflect.Float64:
+		bits := v.Type().Bits()
+		return p.Atom(strconv.FormatFloat(v.Float(), 'g', -1, bits), false, Qcond)
+	case reflect.String:
+		return p.Atom(v.String(), false, Qcond)
+	case reflect.Pointer:
+		k, err := e.enter(v)
+		if err != nil {
+			return err
+		}
+		defer e.leave(k)
+		return e.value(v.Elem(), brace)
+	case reflect.Interface:
+		return e.value(v.Elem(), brace)
+	case reflect.Slice:
+		if v.IsNil() {
+			return marshalNil(p)
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			str := base64.StdEncoding.EncodeToString(v.Bytes())
+			return p.Atom(str, false, Qcond)
+		}
+		k, err := e.enter(v)
+		if err != nil {
+			return err
+		}
+		defer e.leave(k)
+		return marshalList(p, v, brace, e.elem)
+	case reflect.Array:
+		return marshalList(p, v, brace, e.elem)
+	case reflect.Map:
+		if v.IsNil() {
+			return marshalNil(p)
+		}
+		k, err := e.enter(v)
+		if err != nil {
+			return err
+		}
+		defer e.leave(k)
+		return marshalMap(p, v, brace, e.elem)
+	case reflect.Struct:
+		return e.structure(v, brace)
+	}
+	return &UnsupportedTypeError{Type: v.Type()}
+}
+
+func marshalText(p Printer, m encoding.TextMarshaler) error {
+	text, err := m.MarshalText()
+	if err != nil {
+		return err
+	}
+	return p.Atom(string(text), false, Qcond)
+}
+
+// marshalList writes the elements of the slice or array v with elem as
+// sequence. If brace is 0, the sequence is in square brackets.
+func marshalList(p Printer, v reflect.Value, brace rune, elem func(reflect.Value) error) (err error) {
+	if brace == 0 {
+		brace = '['
+	}
+	if err = p.Begin(brace, false); err != nil {
+		return err
+	}
+	for i := 0; i < v.Len(); i++ {
+		if err = elem(v.Index(i)); err != nil {
+			return err
+		}
+	}
+	return p.End()
+}
+
+// marshalMap writes the map v as sequence of alternating keys and values,
+// sorted by key. Values are written with elem. If brace is 0, the sequence is
+// in curly braces.
+func marshalMap(p Printer, v reflect.Value, brace rune, elem func(reflect.Value) error) (err error) {
+	type entry struct {
+		key string
+		val reflect.Value
+	}
+	entries := make([]entry, 0, v.Len())
+	for it := v.MapRange(); it.Next(); {
+		key, err := mapKeyString(it.Key())
+		if err != nil {
+			return err
+		}
+		entries = append(entries, entry{key, it.Value()})
+	}
+	slices.SortFunc(entries, func(a, b entry) int {
+		return strings.Compare(a.key, b.key)
+	})
+	if brace == 0 {
+		brace = '{'
+	}
+	if err = p.Begin(brace, false); err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if err = p.Atom(e.key, false, Qcond); err != nil {
+			return err
+		}
+		if err = elem(e.val); err != nil {
+			return err
+		}
+	}
+	return p.End()
+}
+
+func mapKeyString(k reflect.Value) (string, error) {
+	if k.Type().Implements(textMarshalerType) {
+		if k.Kind() == reflect.Pointer && k.IsNil() {
+			return "", nil
+		}
+		text, err := k.Interface().(encoding.TextMarshaler).MarshalText()
+		return string(text), err
+	}
+	switch k.Kind() {
+	case reflect.String:
+		return k.String(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(k.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(k.Uint(), 10), nil
+	case reflect.Bool:
+		return strconv.FormatBool(k.Bool()), nil
+	}
+	return "", &UnsupportedTypeError{Type: k.Type()}
+}
+
+func (e *encodeState) structure(v reflect.Value, brace rune) (err error) {
+	p := e.p
+	fields, err := structFields(v.Type())
+	if err != nil {
+		return err
+	}
+	if brace == 0 {
+		brace = '{'
+	}
+	if err = p.Begin(brace, false); err != nil {
+		return err
+	}
+	for i := range fields {
+		f := &fields[i]
+		fv, ok := fieldByIndex(v, f.index)
+		if !ok || (f.omitEmpty && isEmptyValue(fv)) {
+			continue
+		}
+		if err = p.Atom(f.name, f.meta, Qcond); err != nil {
+			return err
+		}
+		if err = e.value(fv, f.brace); err != nil {
+			return err
+		}
+	}
+	return p.End()
+}
+
+// fieldByIndex is like reflect.Value.FieldByIndex but it does not panic on
+// nil pointers to embedded structs.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	}
+	return v.IsZero()
+}
+
+// field describes how a struct field is mapped to XSX.
+type field struct {
+	name      string
+	index     []int
+	meta      bool
+	omitEmpty bool
+	brace     rune
+}
+
+var fieldCache sync.Map // reflect.Type → []field or error
+
+func structFields(t reflect.Type) ([]field, error) {
+	if c, ok := fieldCache.Load(t); ok {
+		if err, ok := c.(error); ok {
+			return nil, err
+		}
+		return c.([]field), nil
+	}
+	fields, err := typeFields(t, nil, nil)
+	if err != nil {
+		fieldCache.Store(t, err)
+		return nil, err
+	}
+	fields = dominantFields(fields)
+	fieldCache.Store(t, fields)
+	return fields, nil
+}
+
+func typeFields(t reflect.Type, index []int, fields []field) ([]field, error) {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag, hasTag := sf.Tag.Lookup("xsx")
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "-" && opts == "" {
+			continue
+		}
+		fidx := append(slices.Clone(index), i)
+		if sf.Anonymous && name == "" {
+			ft := sf.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				var err error
+				if fields, err = typeFields(ft, fidx, fields); err != nil {
+					return nil, err
+				}
+				continue
+			}
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		f := field{name: name, index: fidx}
+		if f.name == "" {
+			f.name = sf.Name
+		}
+		if hasTag {
+			for opts != "" {
+				var opt string
+				opt, opts, _ = strings.Cut(opts, ",")
+				switch {
+				case opt == "meta":
+					f.meta = true
+				case opt == "omitempty":
+					f.omitEmpty = true
+				case strings.HasPrefix(opt, "brace="):
+					b := opt[len("brace="):]
+					if len(b) != 1 || openingBrace(b[0]) == 0 {
+						return nil, fmt.Errorf("xsx: illegal brace '%s' in tag of %s.%s",
+							b, t, sf.Name)
+					}
+					f.brace = rune(openingBrace(b[0]))
+				case opt == "":
+				default:
+					return nil, fmt.Errorf("xsx: unknown option '%s' in tag of %s.%s",
+						opt, t, sf.Name)
+				}
+			}
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+// dominantFields removes fields that are hidden by fields with the same name
+// that are less deeply nested in embedded structs.
+func dominantFields(fields []field) (res []field) {
+	for _, f := range fields {
+		if !slices.ContainsFunc(fields, func(g field) bool {
+			return g.name == f.name && len(g.index) < len(f.index)
+		}) {
+			res = append(res, f)
+		}
+	}
+	return res
+}
+
+// openingBrace returns the opening brace for an opening or closing brace b.
+// If b is not a brace, it returns 0.
+func openingBrace(b byte) byte {
+	switch b {
+	case '(', ')':
+		return '('
+	case '[', ']':
+		return '['
+	case '{', '}':
+		return '{'
+	}
+	return 0
+}
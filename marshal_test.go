@@ -0,0 +1,320 @@
+package xsx
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stvp/assert"
+)
+
+type testAddr struct {
+	Host string `xsx:"host"`
+	Port int    `xsx:"port,omitempty"`
+}
+
+type testBase struct {
+	ID      int `xsx:"id,meta"`
+	Comment string
+}
+
+type testServer struct {
+	testBase
+	Name   string            `xsx:"name"`
+	Listen []testAddr        `xsx:"listen,brace=("`
+	Tags   map[string]string `xsx:"tags,omitempty"`
+	Backup *testAddr         `xsx:"backup"`
+	Key    []byte            `xsx:"key,omitempty"`
+	Ratio  float64           `xsx:"ratio"`
+	Off    bool              `xsx:"off"`
+	Skip   int               `xsx:"-"`
+	hidden int
+}
+
+func ExampleMarshal() {
+	srv := testServer{
+		testBase: testBase{ID: 7},
+		Name:     "main server",
+		Listen:   []testAddr{{Host: "localhost", Port: 8080}, {Host: "::"}},
+		Tags:     map[string]string{"zone": "eu", "env": "prod"},
+		Ratio:    .5,
+	}
+	data, err := Marshal(srv)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(string(data))
+	// Output:
+	// {\id 7 Comment "" name "main server" listen({host localhost port 8080}{host ::})tags{env prod zone eu}backup()ratio 0.5 off false}
+}
+
+func ExampleUnmarshal() {
+	var srv testServer
+	err := Unmarshal([]byte(`{
+	name "main server"
+	\id 7
+	listen [(host localhost port 8080)]
+	unknown (is ignored)
+}`), &srv)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Printf("%d %s %+v %v\n", srv.ID, srv.Name, srv.Listen, srv.Backup)
+	// Output:
+	// 7 main server [{Host:localhost Port:8080}] <nil>
+}
+
+func TestMarshal_roundTrip(t *testing.T) {
+	srv := testServer{
+		testBase: testBase{ID: -1, Comment: "with (braces) and \"quotes\""},
+		Name:     "",
+		Listen:   []testAddr{{Port: 1}},
+		Tags:     map[string]string{"a b": "c)d"},
+		Backup:   &testAddr{Host: "backup"},
+		Key:      []byte{0, 1, 2, 254, 255},
+		Ratio:    1e100,
+		Off:      true,
+	}
+	data, err := Marshal(&srv)
+	assert.Nil(t, err)
+	var back testServer
+	err = Unmarshal(data, &back)
+	assert.Nil(t, err, string(data))
+	assert.True(t, reflect.DeepEqual(srv, back), string(data))
+}
+
+func TestUnmarshal_any(t *testing.T) {
+	var x any
+	err := Unmarshal([]byte(`{a [1 2] b () c {d e} f []}`), &x)
+	assert.Nil(t, err)
+	assert.True(t, reflect.DeepEqual(map[string]any{
+		"a": []any{"1", "2"},
+		"b": nil,
+		"c": map[string]any{"d": "e"},
+		"f": []any{},
+	}, x), x)
+}
+
+func TestUnmarshal_scalars(t *testing.T) {
+	var i8 int8
+	assert.Nil(t, Unmarshal([]byte("-128"), &i8))
+	assert.Equal(t, int8(-128), i8)
+	var u uint
+	assert.Nil(t, Unmarshal([]byte("4711"), &u))
+	assert.Equal(t, uint(4711), u)
+	var p *int
+	assert.Nil(t, Unmarshal([]byte("()"), &p))
+	assert.True(t, p == nil)
+	assert.Nil(t, Unmarshal([]byte("3"), &p))
+	assert.Equal(t, 3, *p)
+	var a [3]string
+	assert.Nil(t, Unmarshal([]byte("[x y]"), &a))
+	assert.Equal(t, [3]string{"x", "y", ""}, a)
+	var m map[int]bool
+	assert.Nil(t, Unmarshal([]byte("{1 true 2 false}"), &m))
+	assert.Equal(t, map[int]bool{1: true, 2: false}, m)
+}
+
+func TestMarshal_duration(t *testing.T) {
+	type timeouts struct {
+		Read  time.Duration   `xsx:"read"`
+		Retry []time.Duration `xsx:"retry"`
+	}
+	in := timeouts{Read: 1500 * time.Millisecond, Retry: []time.Duration{time.Second, time.Minute}}
+	data, err := Marshal(in)
+	assert.Nil(t, err)
+	assert.Equal(t, `{read 1.5s retry[1s 1m0s]}`, string(data))
+	var sb strings.Builder
+	assert.Nil(t, Write(Compact(&sb), B('{'), "read", in.Read, "retry", in.Retry, End))
+	assert.Equal(t, string(data), sb.String())
+	var out timeouts
+	assert.Nil(t, Unmarshal(data, &out))
+	assert.Equal(t, in, out)
+	err = Unmarshal([]byte(`{read 1500}`), &out)
+	var typeErr *UnmarshalTypeError
+	assert.True(t, errors.As(err, &typeErr), err)
+}
+
+func TestUnmarshal_errors(t *testing.T) {
+	var i8 int8
+	err := Unmarshal([]byte("128"), &i8)
+	var typeErr *UnmarshalTypeError
+	assert.True(t, errors.As(err, &typeErr), err)
+	assert.True(t, errors.Is(err, strconv.ErrRange), err)
+
+	var srv testServer
+	err = Unmarshal([]byte(`{listen [{host h port x}]}`), &srv)
+	assert.Equal(t,
+		`xsx unmarshal: cannot unmarshal atom "x" into Go value of type int in {listen [… {host …}]} element 3`,
+		fmt.Sprint(err))
+
+	err = Unmarshal([]byte(`{listen [{host h port [1]}]}`), &srv)
+	assert.Equal(t,
+		`xsx unmarshal: cannot unmarshal sequence into Go value of type int in {listen [… {host …}]} element 3`,
+		fmt.Sprint(err))
+	assert.True(t, errors.As(err, &typeErr), err)
+	assert.Equal(t, 3, len(typeErr.Path))
+
+	err = Unmarshal([]byte(`{name x`), &srv)
+	assert.True(t, errors.Is(err, ScanTruncated), err)
+	assert.True(t, Unmarshal([]byte(`{name}`), &srv) != nil)
+	assert.True(t, Unmarshal([]byte(`a b`), &srv) != nil)
+	assert.True(t, Unmarshal([]byte(`a`), srv) != nil)
+	assert.Equal(t, PullEOI, Unmarshal([]byte(` `), &srv))
+}
+
+func TestMarshal_unsupported(t *testing.T) {
+	_, err := Marshal(struct{ C chan int }{})
+	var unsupp *UnsupportedTypeError
+	assert.True(t, errors.As(err, &unsupp), err)
+	_, err = Marshal(struct {
+		F int `xsx:"f,brace=<"`
+	}{})
+	assert.True(t, err != nil)
+}
+
+type testNode struct {
+	Name string
+	Next *testNode
+	Kids []any
+}
+
+type testInner struct{ X int }
+
+type testOuter struct {
+	*testInner
+	Y int
+}
+
+func TestUnmarshal_unexportedEmbedded(t *testing.T) {
+	var o testOuter
+	err := Unmarshal([]byte(`{X 1 Y 2}`), &o)
+	assert.Equal(t,
+		"xsx unmarshal: cannot set embedded pointer to unexported struct xsx.testInner",
+		fmt.Sprint(err))
+	o = testOuter{testInner: &testInner{}}
+	assert.Nil(t, Unmarshal([]byte(`{X 1 Y 2}`), &o))
+	assert.Equal(t, testOuter{&testInner{X: 1}, 2}, o)
+	data, err := Marshal(o)
+	assert.Nil(t, err)
+	assert.Equal(t, `{X 1 Y 2}`, string(data))
+	data, err = Marshal(testOuter{Y: 3})
+	assert.Nil(t, err)
+	assert.Equal(t, `{Y 3}`, string(data))
+}
+
+func TestMarshal_cycle(t *testing.T) {
+	n := &testNode{Name: "a"}
+	n.Next = &testNode{Name: "b", Next: n}
+	_, err := Marshal(n)
+	var unsupp *UnsupportedValueError
+	assert.True(t, errors.As(err, &unsupp), err)
+
+	k := &testNode{Name: "k"}
+	k.Kids = []any{"x", k.Kids}
+	k.Kids[1] = k.Kids
+	_, err = Marshal(k)
+	assert.True(t, errors.As(err, &unsupp), err)
+
+	m := map[string]any{}
+	m["m"] = m
+	_, err = Marshal(m)
+	assert.True(t, errors.As(err, &unsupp), err)
+
+	shared := &testNode{Name: "s"}
+	data, err := Marshal([]*testNode{shared, shared})
+	assert.Nil(t, err)
+	assert.Equal(t, `[{Name s Next()Kids()}{Name s Next()Kids()}]`, string(data))
+}
+
+type testLevel int
+
+const (
+	testDebug testLevel = iota
+	testInfo
+)
+
+func (l testLevel) MarshalXSX(p Printer) error {
+	switch l {
+	case testDebug:
+		return p.Atom("debug", true, Qcond)
+	case testInfo:
+		return p.Atom("info", true, Qcond)
+	}
+	return fmt.Errorf("illegal level %d", int(l))
+}
+
+func (l *testLevel) UnmarshalXSX(p *PullParser) error {
+	if p.LastToken() != TokAtom || !p.WasMeta() {
+		return errors.New("level must be meta atom")
+	}
+	switch p.Atom {
+	case "debug":
+		*l = testDebug
+	case "info":
+		*l = testInfo
+	default:
+		return fmt.Errorf("unknown level '%s'", p.Atom)
+	}
+	return nil
+}
+
+type testEvent struct {
+	Level testLevel            `xsx:"level"`
+	At    time.Time            `xsx:"at"`
+	From  net.IP               `xsx:"from"`
+	Stats map[testLevel]int    `xsx:"stats"`
+	Seen  map[time.Time]net.IP `xsx:"seen,omitempty"`
+}
+
+func (l testLevel) MarshalText() ([]byte, error) {
+	return []byte(strconv.Itoa(int(l))), nil
+}
+
+func (l *testLevel) UnmarshalText(text []byte) error {
+	i, err := strconv.Atoi(string(text))
+	*l = testLevel(i)
+	return err
+}
+
+func ExampleMarshaler() {
+	evt := testEvent{
+		Level: testInfo,
+		At:    time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC),
+		From:  net.IPv4(192, 168, 1, 2),
+		Stats: map[testLevel]int{testDebug: 3, testInfo: 1},
+	}
+	data, err := Marshal(evt)
+	mustExample(err)
+	fmt.Println(string(data))
+	var back testEvent
+	mustExample(Unmarshal(data, &back))
+	fmt.Println(back.Level, back.At, back.From, back.Stats)
+	// Output:
+	// {level \info at 2024-05-01T12:30:00Z from 192.168.1.2 stats{0 3 1 1}}
+	// 1 2024-05-01 12:30:00 +0000 UTC 192.168.1.2 map[0:3 1:1]
+}
+
+func TestUnmarshaler_error(t *testing.T) {
+	var evt testEvent
+	err := Unmarshal([]byte(`{level info}`), &evt)
+	assert.Equal(t, "level must be meta atom", fmt.Sprint(err))
+	err = Unmarshal([]byte(`{at yesterday}`), &evt)
+	var typeErr *UnmarshalTypeError
+	assert.True(t, errors.As(err, &typeErr), err)
+	assert.Equal(t, reflect.TypeFor[time.Time](), typeErr.Type)
+}
+
+func TestWrite_marshaler(t *testing.T) {
+	var sb strings.Builder
+	err := Write(Compact(&sb), B('('), testInfo, net.IPv4(10, 0, 0, 1), End)
+	assert.Nil(t, err)
+	assert.Equal(t, `(\info 10.0.0.1)`, sb.String())
+}
@@ -0,0 +1,52 @@
+package xsx
+
+import "strings"
+
+// PathElem describes one open sequence on the way from the top level of
+// input to the current token.
+type PathElem struct {
+	// Brace is the opening brace of the sequence
+	Brace byte
+	Meta  bool
+	// Index is the 0-based index of the current child within the sequence.
+	// It is -1 if no child of the sequence was read yet.
+	Index int
+	// Head is the first atom of the sequence. It is empty if the sequence has
+	// no children yet or if the first child is not an atom.
+	Head string
+	// HasHead tells whether the first child of the sequence is an atom, i.e.
+	// whether Head is set. This allows to distinguish an empty head atom.
+	HasHead bool
+}
+
+// Path is the stack of open sequences, outermost first.
+type Path []PathElem
+
+// String returns a sketch of p, e.g. "(server (listen …))".
+func (p Path) String() string {
+	if len(p) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	for i, e := range p {
+		if i > 0 {
+			sb.WriteByte(' ')
+		}
+		if e.Meta {
+			sb.Write(metaAtom)
+		}
+		sb.WriteByte(e.Brace)
+		if e.HasHead {
+			CondQuoteTo(e.Head, &sb)
+			if i+1 == len(p) {
+				sb.WriteString(" …")
+			}
+		} else {
+			sb.WriteString("…")
+		}
+	}
+	for i := len(p) - 1; i >= 0; i-- {
+		sb.WriteByte(closing(p[i].Brace))
+	}
+	return sb.String()
+}
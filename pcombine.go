@@ -0,0 +1,308 @@
+package xsx
+
+import (
+	"errors"
+	"fmt"
+)
+
+// TeePrinter forwards all calls to each of its Printers in order, like
+// io.MultiWriter. A call stops at the first Printer that returns an error.
+type TeePrinter struct {
+	Printers []Printer
+}
+
+// Tee returns a TeePrinter that forwards to ps.
+func Tee(ps ...Printer) *TeePrinter {
+	return &TeePrinter{Printers: ps}
+}
+
+func (t *TeePrinter) Begin(bracket rune, meta bool) error {
+	for _, p := range t.Printers {
+		if err := p.Begin(bracket, meta); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *TeePrinter) End() error {
+	for _, p := range t.Printers {
+		if err := p.End(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *TeePrinter) Atom(atom string, meta bool, quote QuoteMode) error {
+	for _, p := range t.Printers {
+		if err := p.Atom(atom, meta, quote); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *TeePrinter) Newline(count int, indent int) error {
+	for _, p := range t.Printers {
+		if err := p.Newline(count, indent); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Comment implements CommentPrinter. Printers that do not implement
+// CommentPrinter are skipped.
+func (t *TeePrinter) Comment(text string, brace byte) error {
+	for _, p := range t.Printers {
+		if err := printerComment(p, text, brace); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes all Printers that have a Close method and returns the joined
+// errors.
+func (t *TeePrinter) Close() error {
+	var errs []error
+	for _, p := range t.Printers {
+		errs = append(errs, closePrinter(p))
+	}
+	return errors.Join(errs...)
+}
+
+// FilterFunc reports whether an expression is dropped by a FilterPrinter.
+// Meta is the meta flag of the expression. For an atom, path is the path of
+// the enclosing sequences, i.e. the Index of the last element is the index
+// of the atom. For a sequence, path also includes the sequence itself as
+// last element. The sequence is checked after its first child, so that the
+// Head is known if the sequence starts with an atom. FilterFunc must not
+// modify or retain path.
+type FilterFunc func(path Path, meta bool) bool
+
+// FilterPrinter forwards all calls to Printer except for those expressions
+// that are dropped by Drop. If a sequence is dropped, the whole subtree is
+// dropped.
+type FilterPrinter struct {
+	Printer Printer
+	Drop    FilterFunc
+	track   pathTracker
+	skip    int   // number of open sequences within a dropped sequence
+	pending bool  // Begin of the innermost sequence is not checked yet
+	held    []any // Newlines and comments that follow a pending Begin
+}
+
+// Filter returns a FilterPrinter that drops the expressions from the output
+// to p for which drop returns true.
+func Filter(p Printer, drop FilterFunc) *FilterPrinter {
+	return &FilterPrinter{Printer: p, Drop: drop}
+}
+
+// DropMeta is a FilterFunc that drops all meta expressions.
+func DropMeta(path Path, meta bool) bool { return meta }
+
+// check decides about the pending sequence. It returns false if the sequence
+// was dropped.
+func (f *FilterPrinter) check() (keep bool, err error) {
+	f.pending = false
+	held := f.held
+	f.held = f.held[:0]
+	e := f.track.path[len(f.track.path)-1]
+	if f.Drop(f.track.path, e.Meta) {
+		f.track.end()
+		f.skip = 1
+		return false, nil
+	}
+	if err = f.Printer.Begin(rune(e.Brace), e.Meta); err != nil {
+		return true, err
+	}
+	return true, Write(f.Printer, held...)
+}
+
+func (f *FilterPrinter) Begin(bracket rune, meta bool) error {
+	if f.skip > 0 {
+		f.skip++
+		return nil
+	}
+	if closing(byte(bracket)) == 0 {
+		return fmt.Errorf("illegal opening bracket '%c'", bracket)
+	}
+	if f.pending {
+		if keep, err := f.check(); err != nil {
+			return err
+		} else if !keep {
+			f.skip++
+			return nil
+		}
+	}
+	f.track.begin(byte(bracket), meta)
+	f.pending = true
+	return nil
+}
+
+func (f *FilterPrinter) End() error {
+	if f.skip > 0 {
+		f.skip--
+		return nil
+	}
+	if len(f.track.path) == 0 {
+		return ErrNothingToEnd
+	}
+	if f.pending {
+		if keep, err := f.check(); err != nil || !keep {
+			f.skip = 0
+			return err
+		}
+	}
+	f.track.end()
+	return f.Printer.End()
+}
+
+func (f *FilterPrinter) Atom(atom string, meta bool, quote QuoteMode) error {
+	if f.skip > 0 {
+		return nil
+	}
+	f.track.atom(atom)
+	if f.pending {
+		if keep, err := f.check(); err != nil || !keep {
+			return err
+		}
+	}
+	if f.Drop(f.track.path, meta) {
+		return nil
+	}
+	return f.Printer.Atom(atom, meta, quote)
+}
+
+func (f *FilterPrinter) Newline(count int, indent int) error {
+	switch {
+	case f.skip > 0:
+		return nil
+	case f.pending:
+		f.held = append(f.held, Nl{Count: count, Indent: indent})
+		return nil
+	}
+	return f.Printer.Newline(count, indent)
+}
+
+// Comment implements CommentPrinter. Comments within dropped sequences are
+// dropped too.
+func (f *FilterPrinter) Comment(text string, brace byte) error {
+	switch {
+	case f.skip > 0:
+		return nil
+	case f.pending:
+		f.held = append(f.held, Comment{Text: text, Brace: brace})
+		return nil
+	}
+	return printerComment(f.Printer, text, brace)
+}
+
+// Depth returns the number of open sequences including the dropped ones.
+func (f *FilterPrinter) Depth() int { return len(f.track.path) + f.skip }
+
+// Close checks that all sequences were ended and closes Printer if it has a
+// Close method.
+func (f *FilterPrinter) Close() error {
+	return errors.Join(closeError(f.Depth()), closePrinter(f.Printer))
+}
+
+// MapPrinter forwards all calls to Printer and replaces the text of each atom
+// with the result of Map. Path is the path of the enclosing sequences as
+// described for FilterFunc. Map must not modify or retain path.
+type MapPrinter struct {
+	Printer Printer
+	Map     func(path Path, atom string) string
+	track   pathTracker
+}
+
+// MapAtoms returns a MapPrinter that rewrites atoms with m before they are
+// written to p.
+func MapAtoms(p Printer, m func(path Path, atom string) string) *MapPrinter {
+	return &MapPrinter{Printer: p, Map: m}
+}
+
+func (m *MapPrinter) Begin(bracket rune, meta bool) error {
+	if err := m.Printer.Begin(bracket, meta); err != nil {
+		return err
+	}
+	m.track.begin(byte(bracket), meta)
+	return nil
+}
+
+func (m *MapPrinter) End() error {
+	if len(m.track.path) == 0 {
+		return ErrNothingToEnd
+	}
+	if err := m.Printer.End(); err != nil {
+		return err
+	}
+	m.track.end()
+	return nil
+}
+
+func (m *MapPrinter) Atom(atom string, meta bool, quote QuoteMode) error {
+	m.track.atom(atom)
+	return m.Printer.Atom(m.Map(m.track.path, atom), meta, quote)
+}
+
+func (m *MapPrinter) Newline(count int, indent int) error {
+	return m.Printer.Newline(count, indent)
+}
+
+// Comment implements CommentPrinter.
+func (m *MapPrinter) Comment(text string, brace byte) error {
+	return printerComment(m.Printer, text, brace)
+}
+
+// Depth returns the number of open sequences.
+func (m *MapPrinter) Depth() int { return len(m.track.path) }
+
+// Close checks that all sequences were ended and closes Printer if it has a
+// Close method.
+func (m *MapPrinter) Close() error {
+	return errors.Join(closeError(m.Depth()), closePrinter(m.Printer))
+}
+
+// pathTracker maintains the Path of the calls to a Printer.
+type pathTracker struct {
+	path Path
+}
+
+func (t *pathTracker) begin(brace byte, meta bool) {
+	if l := len(t.path); l > 0 {
+		t.path[l-1].Index++
+	}
+	t.path = append(t.path, PathElem{Brace: brace, Meta: meta, Index: -1})
+}
+
+func (t *pathTracker) end() {
+	t.path = t.path[:len(t.path)-1]
+}
+
+func (t *pathTracker) atom(atom string) {
+	if l := len(t.path); l > 0 {
+		e := &t.path[l-1]
+		if e.Index++; e.Index == 0 {
+			e.Head, e.HasHead = atom, true
+		}
+	}
+}
+
+// printerComment writes a comment to p if p is a CommentPrinter.
+func printerComment(p Printer, text string, brace byte) error {
+	if cp, ok := p.(CommentPrinter); ok {
+		return cp.Comment(text, brace)
+	}
+	return nil
+}
+
+// closePrinter closes p if p has a Close method.
+func closePrinter(p Printer) error {
+	if c, ok := p.(interface{ Close() error }); ok {
+		return c.Close()
+	}
+	return nil
+}
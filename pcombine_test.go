@@ -0,0 +1,110 @@
+package xsx
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stvp/assert"
+)
+
+func ExampleTee() {
+	var audit strings.Builder
+	redact := func(path Path, atom string) string {
+		if l := len(path); l > 0 && path[l-1].Head == "password" && path[l-1].Index > 0 {
+			return "***"
+		}
+		return atom
+	}
+	p := Tee(
+		Compact(os.Stdout),
+		MapAtoms(Filter(Compact(&audit), DropMeta), redact),
+	)
+	Write(p, B('('), "login", Bm('['), "trace", 4711, End,
+		B('('), "user", "john", End,
+		B('('), "password", "secret", End,
+		End,
+	)
+	fmt.Println()
+	fmt.Println(audit.String())
+	// Output:
+	// (login\[trace 4711](user john)(password secret))
+	// (login(user john)(password ***))
+}
+
+func TestFilter(t *testing.T) {
+	dropHead := func(head string) FilterFunc {
+		return func(path Path, meta bool) bool {
+			l := len(path) - 1
+			return l >= 0 && path[l].HasHead && path[l].Head == head && path[l].Index == 0
+		}
+	}
+	for _, test := range []struct {
+		drop   FilterFunc
+		toks   []any
+		expect string
+	}{
+		{DropMeta, []any{"a", Bm('('), "b", B('['), End, End, "c"}, "a c"},
+		{DropMeta, []any{B('('), "a", Bm('{'), End, "b", End}, "(a b)"},
+		{DropMeta, []any{B('('), Nl{Count: 1}, "a", End}, "(a)"},
+		{dropHead("x"), []any{B('('), "x", "y", End, B('['), B('('), "x", End, End}, "[]"},
+		{dropHead("x"), []any{B('('), B('('), "x", End, "x", End}, "(x)"},
+		{dropHead("x"), []any{"x", B('('), End}, "x()"},
+		{
+			func(path Path, meta bool) bool {
+				l := len(path) - 1
+				return l >= 0 && path[l].Index == 1
+			},
+			[]any{B('('), "a", "b", B('('), "c", End, End},
+			"(a(c))",
+		},
+	} {
+		var sb strings.Builder
+		f := Filter(Compact(&sb), test.drop)
+		assert.Nil(t, Write(f, test.toks...))
+		assert.Nil(t, f.Close())
+		assert.Equal(t, test.expect, sb.String(), test.toks)
+	}
+}
+
+func TestFilter_balance(t *testing.T) {
+	var sb strings.Builder
+	f := Filter(Compact(&sb), DropMeta)
+	assert.Nil(t, Write(f, B('('), Bm('['), B('{')))
+	assert.Equal(t, 3, f.Depth())
+	err := f.Close()
+	assert.True(t, errors.Is(err, ErrOpenSequence), err)
+	assert.Nil(t, Write(f, End, End, End))
+	assert.Nil(t, f.Close())
+	assert.Equal(t, ErrNothingToEnd, f.End())
+	assert.True(t, f.Begin('<', false) != nil)
+	assert.Equal(t, "()", sb.String())
+}
+
+func TestMapAtoms(t *testing.T) {
+	var sb strings.Builder
+	var paths []string
+	m := MapAtoms(Compact(&sb), func(path Path, atom string) string {
+		paths = append(paths, path.String())
+		return strings.ToUpper(atom)
+	})
+	assert.Nil(t, Write(m, "a", B('('), "b", Bm('['), "c", End, "d", End))
+	assert.Nil(t, m.Close())
+	assert.Equal(t, `A(B\[C]D)`, sb.String())
+	assert.Equal(t, []string{"", "(b …)", "(b \\[c …])", "(b …)"}, paths)
+	assert.Equal(t, ErrNothingToEnd, m.End())
+}
+
+func TestTee(t *testing.T) {
+	var sb1, sb2 strings.Builder
+	p := Tee(Compact(&sb1), Indenting(&sb2, " "))
+	assert.Nil(t, Write(p, B('('), "a", Comment{Text: "c"}, End))
+	assert.Nil(t, p.Close())
+	assert.Equal(t, "(a ;c\n)", sb1.String())
+	assert.Equal(t, "(a ;c\n)", sb2.String())
+	assert.Nil(t, p.Begin('(', false))
+	err := p.Close()
+	assert.True(t, errors.Is(err, ErrOpenSequence), err)
+}
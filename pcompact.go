@@ -1,15 +1,17 @@
 package xsx
 
 import (
-	"errors"
 	"fmt"
 	"io"
 )
 
 type CompactPrinter struct {
 	Writer io.Writer
-	nest   []rune
-	sep    bool
+	// Escapes makes the printer escape atoms with EscapeExtTo so that each
+	// atom stays on one line. Use it with a Scanner that has Escapes set.
+	Escapes bool
+	nest    []rune
+	sep     bool
 }
 
 func Compact(wr io.Writer) *CompactPrinter {
@@ -18,6 +20,9 @@ func Compact(wr io.Writer) *CompactPrinter {
 }
 
 func (p *CompactPrinter) Begin(bracket rune, meta bool) error {
+	if closing(byte(bracket)) == 0 {
+		return fmt.Errorf("illegal opening bracket '%c'", bracket)
+	}
 	p.sep = false
 	if meta {
 		if _, err := p.Writer.Write([]byte(MetaStr)); err != nil {
@@ -40,8 +45,6 @@ func (p *CompactPrinter) Begin(bracket rune, meta bool) error {
 			return err
 		}
 		p.nest = append(p.nest, '}')
-	default:
-		return fmt.Errorf("illegal opening bracket '%c'", bracket)
 	}
 	return nil
 }
@@ -49,7 +52,7 @@ func (p *CompactPrinter) Begin(bracket rune, meta bool) error {
 func (p *CompactPrinter) End() (err error) {
 	p.sep = false
 	if len(p.nest) == 0 {
-		return errors.New("nothing to end")
+		return ErrNothingToEnd
 	}
 	b := p.nest[len(p.nest)-1]
 	p.nest = p.nest[:len(p.nest)-1]
@@ -64,9 +67,28 @@ func (p *CompactPrinter) Atom(atom string, meta bool, quote QuoteMode) (err erro
 		}
 	}
 	p.sep = true
-	return printAtom(p.Writer, atom, meta, quote)
+	return printAtom(p.Writer, atom, meta, quote, p.Escapes)
 }
 
+// Depth returns the number of open sequences.
+func (p *CompactPrinter) Depth() int { return len(p.nest) }
+
+// Close checks that all sequences were ended. CompactPrinter does not buffer
+// output and does not close its Writer.
+func (p *CompactPrinter) Close() error { return closeError(len(p.nest)) }
+
 func (p *CompactPrinter) Newline(count int, indent int) error {
 	return nil
 }
+
+// Comment implements CommentPrinter.
+func (p *CompactPrinter) Comment(text string, brace byte) (err error) {
+	brace = commentBrace(text, brace)
+	if p.sep {
+		if _, err = p.Writer.Write([]byte(" ")); err != nil {
+			return err
+		}
+	}
+	p.sep = false
+	return printComment(p.Writer, text, brace, nil)
+}
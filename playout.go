@@ -0,0 +1,287 @@
+package xsx
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// LayoutPrinter lays out XSX to fit a line width. A sequence is written on
+// one line if it fits into the remaining width. Otherwise the sequence is
+// broken: its head atom and the atoms that directly follow the head stay on
+// the line of the opening brace as far as they fit. Each further element
+// starts on a new line that is indented by one level, e.g.
+//
+//	(server main
+//	  (listen localhost 8080)
+//	  (tls \(cert /etc/main.pem)))
+//
+// As layout needs to know a complete top-level expression, LayoutPrinter
+// writes each top-level expression when it is finished. Top-level expressions
+// are separated by newlines. Newline within a sequence forces the sequence to
+// be broken.
+type LayoutPrinter struct {
+	Writer io.Writer
+	// Width is the maximum line width in runes. Elements that do not fit
+	// even on a line of their own exceed the Width.
+	Width  int
+	Indent string
+	// Escapes makes the printer escape atoms with EscapeExtTo, see
+	// CompactPrinter.Escapes.
+	Escapes bool
+	open    []*layoutNode
+	col     int
+	sep     bool // the next top-level expression needs a newline first
+	nl      bool // the next element starts on a new line
+	buf     bytes.Buffer
+}
+
+// layoutNode is an atom, a comment or a sequence that is buffered until its
+// top-level expression is finished.
+type layoutNode struct {
+	text  string // atom or comment or the opening brace with Meta prefix
+	close byte   // closing brace of sequences, 0 otherwise
+	elems []*layoutNode
+	width int  // width when written on one line, -1 if it must be broken
+	brk   bool // sequence must be broken
+	nl    bool // starts on a new line if its sequence is broken
+	line  bool // line comment that must be followed by a newline
+}
+
+// Layout returns a LayoutPrinter that writes to wr with the line width width
+// and indents each nesting level with indent.
+func Layout(wr io.Writer, width int, indent string) *LayoutPrinter {
+	return &LayoutPrinter{Writer: wr, Width: width, Indent: indent}
+}
+
+func (p *LayoutPrinter) Begin(bracket rune, meta bool) error {
+	c := closing(byte(bracket))
+	if c == 0 {
+		return fmt.Errorf("illegal opening bracket '%c'", bracket)
+	}
+	n := &layoutNode{text: string(bracket), close: c}
+	if meta {
+		n.text = MetaStr + n.text
+	}
+	p.open = append(p.open, n)
+	return nil
+}
+
+func (p *LayoutPrinter) End() error {
+	l := len(p.open) - 1
+	if l < 0 {
+		return ErrNothingToEnd
+	}
+	n := p.open[l]
+	p.open = p.open[:l]
+	p.nl = false
+	n.width = utf8.RuneCountInString(n.text) + 1
+	if n.brk {
+		n.width = -1
+	}
+	for i, e := range n.elems {
+		if e.width < 0 || n.width < 0 {
+			n.width = -1
+			break
+		}
+		if i > 0 {
+			n.width++
+		}
+		n.width += e.width
+	}
+	return p.add(n)
+}
+
+func (p *LayoutPrinter) Atom(atom string, meta bool, quote QuoteMode) error {
+	p.buf.Reset()
+	if err := printAtom(&p.buf, atom, meta, quote, p.Escapes); err != nil {
+		return err
+	}
+	n := &layoutNode{text: p.buf.String()}
+	if bytes.IndexByte(p.buf.Bytes(), '\n') >= 0 {
+		n.width = -1
+	} else {
+		n.width = utf8.RuneCountInString(n.text)
+	}
+	return p.add(n)
+}
+
+// Newline writes count newlines between top-level expressions. Within a
+// sequence it forces the sequence to be broken with the next element on a new
+// line. Indent is ignored.
+func (p *LayoutPrinter) Newline(count int, indent int) error {
+	if l := len(p.open); l > 0 {
+		p.open[l-1].brk = true
+		p.nl = true
+		return nil
+	}
+	if count > 0 {
+		if err := p.write(strings.Repeat("\n", count)); err != nil {
+			return err
+		}
+		p.col = 0
+		p.sep = false
+	}
+	return nil
+}
+
+// Depth returns the number of open sequences.
+func (p *LayoutPrinter) Depth() int { return len(p.open) }
+
+// Close checks that all sequences were ended. As LayoutPrinter writes each
+// top-level expression when it is finished, the output of an unfinished
+// expression is lost.
+func (p *LayoutPrinter) Close() error { return closeError(len(p.open)) }
+
+// Comment implements CommentPrinter.
+func (p *LayoutPrinter) Comment(text string, brace byte) error {
+	brace = commentBrace(text, brace)
+	if brace != 0 {
+		p.buf.Reset()
+		if err := printComment(&p.buf, text, brace, nil); err != nil {
+			return err
+		}
+		n := &layoutNode{text: p.buf.String(), width: -1}
+		if !strings.Contains(n.text, "\n") {
+			n.width = utf8.RuneCountInString(n.text)
+		}
+		return p.add(n)
+	}
+	for _, line := range strings.Split(text, "\n") {
+		n := &layoutNode{text: string(CommentStart) + line, width: -1, line: true}
+		if err := p.add(n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// add adds n to the open sequence or writes n if it is a top-level
+// expression.
+func (p *LayoutPrinter) add(n *layoutNode) (err error) {
+	if l := len(p.open); l > 0 {
+		s := p.open[l-1]
+		s.elems = append(s.elems, n)
+		n.nl, p.nl = p.nl, false
+		return nil
+	}
+	if p.sep {
+		if err = p.newline(0); err != nil {
+			return err
+		}
+	}
+	if err = p.layout(n, 0, 0); err != nil {
+		return err
+	}
+	if n.line {
+		err = p.newline(0)
+		p.sep = false
+	} else {
+		p.sep = true
+	}
+	return err
+}
+
+// layout writes n at nesting level lvl. Trail is the number of runes that
+// follow n on the same line, i.e. the closing braces of enclosing sequences.
+func (p *LayoutPrinter) layout(n *layoutNode, lvl, trail int) (err error) {
+	if n.close == 0 {
+		return p.write(n.text)
+	}
+	if n.width >= 0 && (p.Width <= 0 || p.col+n.width+trail <= p.Width) {
+		return p.flat(n)
+	}
+	if err = p.write(n.text); err != nil {
+		return err
+	}
+	elems := n.elems
+	for i, e := range elems {
+		if e.close != 0 || e.line || e.nl || e.width < 0 {
+			break
+		}
+		if i > 0 {
+			w := 1 + e.width
+			if i == len(n.elems)-1 {
+				w += 1 + trail
+			}
+			if p.Width > 0 && p.col+w > p.Width {
+				break
+			}
+			if err = p.write(" "); err != nil {
+				return err
+			}
+		}
+		if err = p.write(e.text); err != nil {
+			return err
+		}
+		elems = n.elems[i+1:]
+	}
+	for i, e := range elems {
+		if err = p.newline(lvl + 1); err != nil {
+			return err
+		}
+		t := 0
+		if i == len(elems)-1 {
+			t = trail + 1
+		}
+		if err = p.layout(e, lvl+1, t); err != nil {
+			return err
+		}
+	}
+	if l := len(n.elems); l > 0 && n.elems[l-1].line {
+		if err = p.newline(lvl); err != nil {
+			return err
+		}
+	}
+	return p.write(string(n.close))
+}
+
+// flat writes n on a single line.
+func (p *LayoutPrinter) flat(n *layoutNode) (err error) {
+	if err = p.write(n.text); err != nil {
+		return err
+	}
+	for i, e := range n.elems {
+		if i > 0 {
+			if err = p.write(" "); err != nil {
+				return err
+			}
+		}
+		if e.close == 0 {
+			err = p.write(e.text)
+		} else {
+			err = p.flat(e)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return p.write(string(n.close))
+}
+
+func (p *LayoutPrinter) newline(lvl int) (err error) {
+	if err = p.write("\n"); err != nil {
+		return err
+	}
+	p.col = 0
+	for ; lvl > 0; lvl-- {
+		if err = p.write(p.Indent); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *LayoutPrinter) write(s string) error {
+	if _, err := io.WriteString(p.Writer, s); err != nil {
+		return err
+	}
+	if nl := strings.LastIndexByte(s, '\n'); nl >= 0 {
+		p.col = utf8.RuneCountInString(s[nl+1:])
+	} else {
+		p.col += utf8.RuneCountInString(s)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package xsx
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stvp/assert"
+)
+
+func ExampleLayout() {
+	cfg := []any{
+		B('('), "server", "main",
+		B('('), "listen", "localhost", 8080, End,
+		B('('), "tls", Bm('('), "cert", "/etc/xsx/main.pem", End, Bm('('), "key", "/etc/xsx/main.key", End, End,
+		End,
+		B('['), 1, 2, 3, End,
+	}
+	mustExample(Write(Layout(os.Stdout, 40, "  "), cfg...))
+	os.Stdout.WriteString("\n---\n")
+	mustExample(Write(Layout(os.Stdout, 0, "  "), cfg...))
+	// Output:
+	// (server main
+	//   (listen localhost 8080)
+	//   (tls
+	//     \(cert /etc/xsx/main.pem)
+	//     \(key /etc/xsx/main.key)))
+	// [1 2 3]
+	// ---
+	// (server main (listen localhost 8080) (tls \(cert /etc/xsx/main.pem) \(key /etc/xsx/main.key)))
+	// [1 2 3]
+}
+
+func TestLayoutPrinter_comments(t *testing.T) {
+	var sb strings.Builder
+	p := Layout(&sb, 20, "\t")
+	assert.Nil(t, Write(p, Comment{Text: "top"}, B('('), "a", Comment{Text: "c"}, End))
+	assert.Nil(t, Write(p, B('('), "b", Comment{Text: "x", Brace: '('}, "c", End))
+	assert.Nil(t, Write(p, B('('), "d", Nl{Count: 1}, "e", End))
+	assert.Equal(t, ";top\n(a\n\t;c\n)\n(b ;(x) c)\n(d\n\te)", sb.String())
+}
+
+// scanEvents returns the events scanned from txt as string.
+func scanEvents(t *testing.T, txt string) string {
+	var sb strings.Builder
+	s := NewScanner(
+		func(meta bool, brace byte) { fmt.Fprintf(&sb, "%t%c ", meta, brace) },
+		func(meta bool, brace byte) { fmt.Fprintf(&sb, "%c ", brace) },
+		func(meta bool, atom []byte, quoted bool) { fmt.Fprintf(&sb, "%t%q ", meta, atom) },
+	)
+	assert.Nil(t, s.ScanString(txt))
+	return sb.String()
+}
+
+// randomTokens returns the tokens of a random expression for Write.
+func randomTokens(rnd *rand.Rand, depth int, toks []any) []any {
+	if depth == 0 || rnd.Intn(3) == 0 {
+		atom := strings.Repeat(string(rune('a'+rnd.Intn(26))), 1+rnd.Intn(8))
+		if rnd.Intn(10) == 0 {
+			return append(toks, atom+" "+atom)
+		}
+		return append(toks, atom)
+	}
+	toks = append(toks, B("([{"[rnd.Intn(3)]))
+	for n := rnd.Intn(6); n > 0; n-- {
+		toks = randomTokens(rnd, depth-1, toks)
+	}
+	return append(toks, End)
+}
+
+func TestLayoutPrinter_random(t *testing.T) {
+	rnd := rand.New(rand.NewSource(4711))
+	for i := 0; i < 200; i++ {
+		toks := randomTokens(rnd, 5, nil)
+		var compact, layout strings.Builder
+		assert.Nil(t, Write(Compact(&compact), toks...))
+		width := 10 + rnd.Intn(60)
+		assert.Nil(t, Write(Layout(&layout, width, "  "), toks...))
+		if !strings.Contains(layout.String(), "\n") {
+			assert.True(t, utf8.RuneCountInString(layout.String()) <= width)
+		}
+		assert.Equal(t, scanEvents(t, compact.String()), scanEvents(t, layout.String()))
+	}
+}
@@ -6,8 +6,11 @@ import (
 )
 
 type IndentingPrinter struct {
-	Writer  io.Writer
-	Indent  string
+	Writer io.Writer
+	Indent string
+	// Escapes makes the printer escape atoms with EscapeExtTo, see
+	// CompactPrinter.Escapes.
+	Escapes bool
 	nest    []rune
 	indlvl  int
 	needind bool
@@ -34,6 +37,9 @@ func (p *IndentingPrinter) doIndent() (err error) {
 }
 
 func (p *IndentingPrinter) Begin(bracket rune, meta bool) (err error) {
+	if closing(byte(bracket)) == 0 {
+		return fmt.Errorf("illegal opening bracket '%c'", bracket)
+	}
 	if err = p.doIndent(); err != nil {
 		return err
 	}
@@ -48,18 +54,17 @@ func (p *IndentingPrinter) Begin(bracket rune, meta bool) (err error) {
 			return err
 		}
 	}
-	if isAny(byte(bracket), ccBegin) {
-		if _, err := p.Writer.Write([]byte{byte(bracket)}); err != nil {
-			return err
-		}
-		p.nest = append(p.nest, closingRune(bracket))
-	} else {
-		return fmt.Errorf("illegal opening bracket '%c'", bracket)
+	if _, err := p.Writer.Write([]byte{byte(bracket)}); err != nil {
+		return err
 	}
+	p.nest = append(p.nest, closingRune(bracket))
 	return nil
 }
 
 func (p *IndentingPrinter) End() (err error) {
+	if len(p.nest) == 0 {
+		return ErrNothingToEnd
+	}
 	if err = p.doIndent(); err != nil {
 		return err
 	}
@@ -81,9 +86,15 @@ func (p *IndentingPrinter) Atom(atom string, meta bool, quote QuoteMode) (err er
 	} else {
 		p.needsep = true
 	}
-	return printAtom(p.Writer, atom, meta, quote)
+	return printAtom(p.Writer, atom, meta, quote, p.Escapes)
 }
 
+// Depth returns the number of open sequences.
+func (p *IndentingPrinter) Depth() int { return len(p.nest) }
+
+// Close checks that all sequences were ended. See CompactPrinter.Close.
+func (p *IndentingPrinter) Close() error { return closeError(len(p.nest)) }
+
 func (p *IndentingPrinter) Newline(count int, indent int) (err error) {
 	for count > 0 {
 		if _, err = p.Writer.Write([]byte("\n")); err != nil {
@@ -96,3 +107,30 @@ func (p *IndentingPrinter) Newline(count int, indent int) (err error) {
 	p.needsep = false
 	return err
 }
+
+// Comment implements CommentPrinter.
+func (p *IndentingPrinter) Comment(text string, brace byte) (err error) {
+	brace = commentBrace(text, brace)
+	if err = p.doIndent(); err != nil {
+		return err
+	}
+	if p.needsep {
+		if _, err = p.Writer.Write([]byte(" ")); err != nil {
+			return err
+		}
+	}
+	indent := func() error {
+		p.needind = true
+		return p.doIndent()
+	}
+	if err = printComment(p.Writer, text, brace, indent); err != nil {
+		return err
+	}
+	if brace == 0 {
+		p.needind = true
+		p.needsep = false
+	} else {
+		p.needsep = true
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package xsx
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// Position is a location in the input of a Scanner. Offset is the 0-based byte
+// offset from the start of input. Line and Col are 1-based where Col counts
+// runes, not bytes.
+type Position struct {
+	Offset int64
+	Line   int
+	Col    int
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Col)
+}
+
+// IsValid reports whether p is a position computed by a Scanner. The zero
+// Position is not valid.
+func (p Position) IsValid() bool { return p.Line > 0 }
+
+var nlBytes = []byte{'\n'}
+
+// advance moves p over txt, which must be the input starting at p.
+func (p *Position) advance(txt []byte) {
+	if p.Line == 0 {
+		p.Line, p.Col = 1, 1
+	}
+	p.Offset += int64(len(txt))
+	if nl := bytes.LastIndexByte(txt, '\n'); nl >= 0 {
+		p.Line += bytes.Count(txt[:nl], nlBytes) + 1
+		p.Col = 1
+		txt = txt[nl+1:]
+	}
+	col := p.Col
+	for _, c := range txt {
+		// count everything that is not an UTF-8 continuation byte
+		if c&0xC0 != 0x80 {
+			col++
+		}
+	}
+	p.Col = col
+}
+
+// next returns the position after a single byte at p that is not a newline.
+func (p Position) next() Position {
+	p.Offset++
+	p.Col++
+	return p
+}
@@ -0,0 +1,74 @@
+package xsx
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"testing"
+)
+
+func posScanner(wr *bytes.Buffer) (s *Scanner) {
+	s = NewScanner(
+		func(meta bool, brace byte) {
+			b, e := s.Pos()
+			fmt.Fprintf(wr, "begin %c %s-%s [%d,%d)\n", brace, b, e, b.Offset, e.Offset)
+		},
+		func(meta bool, brace byte) {
+			b, e := s.Pos()
+			fmt.Fprintf(wr, "end %c %s-%s [%d,%d)\n", brace, b, e, b.Offset, e.Offset)
+		},
+		func(meta bool, atom []byte, quoted bool) {
+			b, e := s.Pos()
+			fmt.Fprintf(wr, "atom %s %s-%s [%d,%d)\n", atom, b, e, b.Offset, e.Offset)
+		},
+	)
+	return s
+}
+
+func ExampleScanner_Pos() {
+	var out bytes.Buffer
+	s := posScanner(&out)
+	mustExample(s.ScanString("(foo\n  \\\"bär\" \\[ä]\n)\\"))
+	os.Stdout.Write(out.Bytes())
+	// Output:
+	// begin ( 1:1-1:2 [0,1)
+	// atom foo 1:2-1:5 [1,4)
+	// atom bär 2:3-2:9 [7,14)
+	// begin [ 2:10-2:12 [15,17)
+	// atom ä 2:12-2:13 [17,19)
+	// end ] 2:13-2:14 [19,20)
+	// end ) 3:1-3:2 [21,22)
+	// atom \ 3:2-3:3 [22,23)
+}
+
+func TestScanner_PosSplit(t *testing.T) {
+	checkSplitInvariant(t, posScanner, "(foo \"bär\\\"\"\n\\x \\ [äö]\n\\\\ \\{ü\n })lästig")
+}
+
+// checkSplitInvariant checks that the output of the scanners created by
+// newScanner does not change when in is scanned in up to three chunks at any
+// split positions.
+func checkSplitInvariant(t *testing.T, newScanner func(*bytes.Buffer) *Scanner, in string) {
+	t.Helper()
+	var expect, out bytes.Buffer
+	if err := newScanner(&expect).ScanString(in); err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i < len(in); i++ {
+		for j := i; j < len(in); j++ {
+			out.Reset()
+			s := newScanner(&out)
+			for _, chunk := range []string{in[:i], in[i:j], in[j:]} {
+				if err := s.Scan([]byte(chunk)); err != nil {
+					t.Fatalf("split %d %d: %s", i, j, err)
+				}
+			}
+			if err := s.Finish(); err != nil {
+				t.Fatalf("split %d %d: %s", i, j, err)
+			}
+			if !bytes.Equal(expect.Bytes(), out.Bytes()) {
+				t.Fatalf("split %d %d:\n%s------\n%s", i, j, expect.String(), out.String())
+			}
+		}
+	}
+}
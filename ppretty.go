@@ -1,7 +1,6 @@
 package xsx
 
 import (
-	"errors"
 	"fmt"
 	"io"
 )
@@ -9,9 +8,12 @@ import (
 // PrettyPrinter currently is a quick'n'dirty impl that is about to change
 type PrettyPrinter struct {
 	Writer io.Writer
-	ilvl   int
-	istr   []byte
-	ends   []rune
+	// Escapes makes the printer escape atoms with EscapeExtTo, see
+	// CompactPrinter.Escapes.
+	Escapes bool
+	ilvl    int
+	istr    []byte
+	ends    []rune
 }
 
 func Pretty(wr io.Writer, indent string) *PrettyPrinter {
@@ -33,6 +35,9 @@ func (pp *PrettyPrinter) indent() (err error) {
 }
 
 func (pp *PrettyPrinter) Begin(bracket rune, meta bool) (err error) {
+	if closing(byte(bracket)) == 0 {
+		return fmt.Errorf("illegal opening bracket '%c'", bracket)
+	}
 	err = pp.indent()
 	if err != nil {
 		return err
@@ -51,7 +56,7 @@ func (pp *PrettyPrinter) Begin(bracket rune, meta bool) (err error) {
 
 func (pp *PrettyPrinter) End() (err error) {
 	if len(pp.ends) == 0 {
-		return errors.New("nothing to end")
+		return ErrNothingToEnd
 	}
 	end := pp.ends[len(pp.ends)-1]
 	pp.ends = pp.ends[:len(pp.ends)-1]
@@ -69,7 +74,7 @@ func (pp *PrettyPrinter) Atom(atom string, meta bool, quote QuoteMode) (err erro
 	if err != nil {
 		return err
 	}
-	err = printAtom(pp.Writer, atom, meta, quote)
+	err = printAtom(pp.Writer, atom, meta, quote, pp.Escapes)
 	if err != nil {
 		return err
 	}
@@ -77,4 +82,25 @@ func (pp *PrettyPrinter) Atom(atom string, meta bool, quote QuoteMode) (err erro
 	return err
 }
 
+// Comment implements CommentPrinter.
+func (pp *PrettyPrinter) Comment(text string, brace byte) (err error) {
+	brace = commentBrace(text, brace)
+	if err = pp.indent(); err != nil {
+		return err
+	}
+	if err = printComment(pp.Writer, text, brace, pp.indent); err != nil {
+		return err
+	}
+	if brace != 0 {
+		_, err = fmt.Fprintln(pp.Writer)
+	}
+	return err
+}
+
 func (p *PrettyPrinter) Newline(count int, indent int) error { return nil }
+
+// Depth returns the number of open sequences.
+func (pp *PrettyPrinter) Depth() int { return len(pp.ends) }
+
+// Close checks that all sequences were ended. See CompactPrinter.Close.
+func (pp *PrettyPrinter) Close() error { return closeError(len(pp.ends)) }
@@ -1,7 +1,10 @@
 package xsx
 
 import (
+	"errors"
+	"fmt"
 	"io"
+	"strings"
 )
 
 type Printer interface {
@@ -11,11 +14,98 @@ type Printer interface {
 	Newline(count int, indent int) error
 }
 
+var (
+	// ErrNothingToEnd is returned by the End method of the printers when
+	// there is no open sequence.
+	ErrNothingToEnd = errors.New("nothing to end")
+	// ErrOpenSequence is returned by the Close method of the printers when
+	// sequences are still open.
+	ErrOpenSequence = errors.New("sequence left open")
+)
+
+// closeError returns the error for Close when depth sequences are open.
+func closeError(depth int) error {
+	if depth == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: depth %d", ErrOpenSequence, depth)
+}
+
+// CommentPrinter is implemented by Printers that can write comments. Brace
+// is the opening brace of a block comment or 0 for a line comment. A line
+// comment is terminated with a newline and each line of a multi-line text
+// becomes a line comment of its own. The text of a block comment that would
+// not be read back as such, i.e. with unbalanced braces or quotes, is also
+// written as line comments. See also CommentStart.
+type CommentPrinter interface {
+	Comment(text string, brace byte) error
+}
+
+// commentBrace returns the brace to write a comment with. It is 0, i.e. a
+// line comment, if text cannot be read back from a block comment.
+func commentBrace(text string, brace byte) byte {
+	if brace == 0 || openingBrace(brace) != brace {
+		return brace
+	}
+	depth, quote, esc := 0, false, false
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case esc:
+			esc = false
+		case quote:
+			switch c {
+			case '\\':
+				esc = true
+			case '"':
+				quote = false
+			}
+		case c == '"':
+			quote = true
+		case isAny(c, ccBegin):
+			depth++
+		case isAny(c, ccEnd):
+			if depth--; depth < 0 {
+				return 0
+			}
+		}
+	}
+	if depth != 0 || quote {
+		return 0
+	}
+	return brace
+}
+
+// printComment writes a comment to wr. For multi-line line comments indent is
+// called before each line but the first, if indent is not nil.
+func printComment(wr io.Writer, text string, brace byte, indent func() error) (err error) {
+	if brace != 0 {
+		if openingBrace(brace) != brace {
+			return fmt.Errorf("illegal comment brace '%c'", brace)
+		}
+		_, err = fmt.Fprintf(wr, "%c%c%s%c", CommentStart, brace, text, closing(brace))
+		return err
+	}
+	for i, line := range strings.Split(text, "\n") {
+		if i > 0 && indent != nil {
+			if err = indent(); err != nil {
+				return err
+			}
+		}
+		if _, err = fmt.Fprintf(wr, "%c%s\n", CommentStart, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func closingRune(open rune) rune {
 	return rune(closing(byte(open)))
 }
 
-func printAtom(wr io.Writer, atom string, meta bool, quote QuoteMode) (err error) {
+// printAtom writes atom to wr. With esc, the atom is escaped with the
+// extended escape dialect, see EscapeExtTo.
+func printAtom(wr io.Writer, atom string, meta bool, quote QuoteMode, esc bool) (err error) {
 	if meta {
 		_, err = wr.Write(metaAtom)
 		if err != nil {
@@ -23,6 +113,22 @@ func printAtom(wr io.Writer, atom string, meta bool, quote QuoteMode) (err error
 		}
 	}
 	switch quote {
+	case Qraw:
+		return RawQuoteTo(atom, wr)
+	case QcondRaw:
+		if PreferRaw(atom, esc) {
+			return RawQuoteTo(atom, wr)
+		}
+		quote = Qcond
+	}
+	if esc && quote != QSUPPRESS {
+		if quote == Qforce || NeedQuoteExt(atom) {
+			return QuoteExtTo(atom, wr)
+		}
+		_, err = wr.Write([]byte(atom))
+		return err
+	}
+	switch quote {
 	case Qcond:
 		_, err = CondQuoteTo(atom, wr)
 	case Qforce:
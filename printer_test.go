@@ -0,0 +1,110 @@
+package xsx
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stvp/assert"
+)
+
+// checkedPrinter is implemented by all printers of this package.
+type checkedPrinter interface {
+	Printer
+	CommentPrinter
+	Depth() int
+	Close() error
+}
+
+var testPrinters = map[string]func(io.Writer) checkedPrinter{
+	"compact":   func(w io.Writer) checkedPrinter { return Compact(w) },
+	"indenting": func(w io.Writer) checkedPrinter { return Indenting(w, "  ") },
+	"pretty":    func(w io.Writer) checkedPrinter { return Pretty(w, "  ") },
+	"layout":    func(w io.Writer) checkedPrinter { return Layout(w, 20, "  ") },
+}
+
+// forPrinters runs test for each printer of this package.
+func forPrinters(t *testing.T, test func(t *testing.T, sb *strings.Builder, p checkedPrinter)) {
+	for name, newPrinter := range testPrinters {
+		t.Run(name, func(t *testing.T) {
+			var sb strings.Builder
+			test(t, &sb, newPrinter(&sb))
+		})
+	}
+}
+
+func TestPrinters_balanced(t *testing.T) {
+	forPrinters(t, func(t *testing.T, sb *strings.Builder, p checkedPrinter) {
+		assert.Equal(t, 0, p.Depth())
+		assert.Nil(t, p.Begin('(', false))
+		assert.Nil(t, p.Begin('[', true))
+		assert.Equal(t, 2, p.Depth())
+		assert.Nil(t, p.End())
+		assert.Equal(t, 1, p.Depth())
+		err := p.Close()
+		assert.True(t, errors.Is(err, ErrOpenSequence), err)
+		assert.Nil(t, p.End())
+		assert.Nil(t, p.Close())
+		assert.Equal(t, ErrNothingToEnd, p.End())
+		assert.Equal(t, 0, p.Depth())
+	})
+}
+
+func TestPrinters_illegalBrace(t *testing.T) {
+	forPrinters(t, func(t *testing.T, sb *strings.Builder, p checkedPrinter) {
+		assert.Nil(t, Write(p, "a", B('(')))
+		out := sb.String()
+		assert.True(t, p.Begin('<', false) != nil)
+		assert.True(t, p.Begin('<', true) != nil)
+		assert.Equal(t, 1, p.Depth())
+		assert.Equal(t, out, sb.String())
+		assert.Nil(t, Write(p, "b", End))
+		out = sb.String()
+		assert.True(t, p.Begin('<', true) != nil)
+		assert.Equal(t, 0, p.Depth())
+		assert.Equal(t, out, sb.String())
+		assert.Nil(t, p.Close())
+	})
+}
+
+func TestPrinters_rescan(t *testing.T) {
+	toks := []any{
+		B('('), "server", "main server", Nl{Count: 1, Indent: 1},
+		Bm('['), "meta", "", End,
+		Comment{Text: "line\ncomment"}, Comment{Text: "block", Brace: '{'},
+		Comment{Text: "x\"y", Brace: '('}, Comment{Text: "a)b", Brace: '['},
+		Comment{Text: `(q ")" \"\\")`, Brace: '('},
+		B('{'), `"q"`, End, Nl{Count: 1, Indent: -1},
+		End,
+		"top", B('('), End,
+	}
+	const expect = `false( false"server" false"main server" ` +
+		`true[ false"meta" false"" ] ;"line" ;"comment" ;{"block" ;"x\"y" ;"a)b" ` +
+		`;("(q \")\" \\\"\\\\\")" false{ false"\"q\"" } ) false"top" false( ) `
+	forPrinters(t, func(t *testing.T, sb *strings.Builder, p checkedPrinter) {
+		assert.Nil(t, Write(p, toks...))
+		assert.Nil(t, p.Close())
+		var events strings.Builder
+		s := NewScanner(
+			func(meta bool, brace byte) { events.WriteString(fmtBool(meta) + string(brace) + " ") },
+			func(meta bool, brace byte) { events.WriteString(string(brace) + " ") },
+			func(meta bool, atom []byte, quoted bool) {
+				events.WriteString(fmtBool(meta) + Quoted(string(atom)) + " ")
+			},
+		)
+		s.Comments = true
+		s.Comment = func(text []byte, brace byte) {
+			events.WriteString(";" + strings.TrimRight(string(brace), "\x00") + Quoted(string(text)) + " ")
+		}
+		assert.Nil(t, s.ScanString(sb.String()), sb.String())
+		assert.Equal(t, expect, events.String(), sb.String())
+	})
+}
+
+func fmtBool(b bool) string {
+	if b {
+		return "true"
+	}
+	return "false"
+}
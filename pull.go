@@ -1,9 +1,10 @@
 package xsx
 
 import (
-	"bufio"
+	"errors"
 	"fmt"
 	"io"
+	"iter"
 	"strconv"
 	"strings"
 )
@@ -16,6 +17,9 @@ const (
 	TokEnd
 	TokAtom
 	TokEOI
+	// TokComment is only returned if comments are pulled, see
+	// PullParser.PullComments.
+	TokComment
 )
 
 func (t Token) String() string {
@@ -30,104 +34,455 @@ func (t Token) String() string {
 		return "end"
 	case TokAtom:
 		return "atom"
+	case TokComment:
+		return "comment"
 	default:
 		return fmt.Sprintf("<illgeal token: %d>", t)
 	}
 }
 
-type tokInfo struct {
+// pullTok is a token queued by the PullParser. The atom text of TokAtom
+// tokens is atoms[atom:atomEnd] of the PullParser.
+type pullTok struct {
 	tok     Token
 	meta    bool
+	quoted  bool
+	raw     bool
 	bracket byte
+	atom    int
+	atomEnd int
 }
 
+// PullParser reads XSX tokens from an io.Reader one by one. It reads input in
+// blocks and queues the tokens that were scanned from one block. The atom
+// of the last token pulled with Next is available from the Atom field and,
+// without allocation, from AtomBytes. The Limits of a PullParser are checked
+// like those of a Scanner.
 type PullParser struct {
-	rd           io.Reader
-	buf          []byte
-	tokWr, tokRd int
-	toks         [2]tokInfo
-	Atom         string // atom can only appear in tokInfo[0]
-	WasQuot      bool
-	scn          *Scanner
+	Limits
+	rd      io.Reader
+	buf     []byte
+	eoi     bool
+	eoiErr  error // reported with the first TokEOI
+	lastErr error // error reported with the last TokEOI, for Unread
+	toks    []pullTok
+	tokRd   int    // index of the next token in toks to be returned by Next
+	atoms   []byte // text of all atoms in toks
+	Atom    string
+	WasQuot bool
+	// Intern, if not nil, is used by Next to convert atoms to the Atom string
+	// instead of allocating a new string for each atom. See Interner and
+	// InternNone.
+	Intern func(atom []byte) string
+	// Comments enables the comment syntax, see CommentStart. Comments are
+	// skipped unless PullComments is set too. Then comments are returned as
+	// TokComment with the comment's text in Atom and the opening brace of
+	// block comments as LastBrace.
+	Comments     bool
+	PullComments bool
+	// Escapes enables the extended escape dialect, see Scanner.Escapes.
+	Escapes bool
+	// UTF8 and CheckUTF8 work like Scanner.UTF8 and Scanner.CheckUTF8.
+	UTF8      bool
+	CheckUTF8 bool
+	// RawAtoms enables raw atoms, see RawStart and WasRaw.
+	RawAtoms bool
+	scn      *Scanner
+	cmtFn    CommentFunc
+	unread   bool // true if the last token may be unread
+	path     []pathElem
+	heads    []byte   // text of the head atoms in path
+	popped   pathElem // the element popped by the last TokEnd, for Unread
 }
 
-func (pp *PullParser) read() error {
-	_, err := pp.rd.Read(pp.buf)
-	return err
+// pathElem is the internal form of PathElem. The head atom is
+// heads[hs:he] of the PullParser.
+type pathElem struct {
+	brace   byte
+	meta    bool
+	hasHead bool
+	index   int
+	hs, he  int
+}
+
+// InternNone can be used as PullParser.Intern to not set the Atom field at
+// all. This is useful when atoms are only accessed with AtomBytes. Note that
+// readers which rely on the Atom field will not work then.
+func InternNone(atom []byte) string { return "" }
+
+// PullBufferSize is the default size of the blocks read by PullParser.
+const PullBufferSize = 4096
+
+// NewPullParser creates a PullParser that reads from rd. As the PullParser
+// reads in blocks, rd does not need to be buffered.
+func NewPullParser(rd io.Reader) *PullParser {
+	return NewPullParserSize(rd, PullBufferSize)
 }
 
-func NewPullParser(rd *bufio.Reader) *PullParser {
-	res := &PullParser{rd: rd, buf: make([]byte, 1)}
-	scn := NewScanner(
+// NewPullParserSize creates a PullParser that reads blocks of size bytes
+// from rd.
+func NewPullParserSize(rd io.Reader, size int) *PullParser {
+	if size <= 0 {
+		size = PullBufferSize
+	}
+	res := &PullParser{rd: rd, buf: make([]byte, size)}
+	res.scn = NewScanner(
 		func(isMeta bool, bracket byte) {
-			ti := &res.toks[res.tokWr]
-			ti.tok = TokBegin
-			ti.bracket = bracket
-			ti.meta = isMeta
-			res.tokWr++
+			res.toks = append(res.toks, pullTok{
+				tok:     TokBegin,
+				meta:    isMeta,
+				bracket: bracket,
+			})
 		},
 		func(isMeta bool, bracket byte) {
-			ti := &res.toks[res.tokWr]
-			ti.tok = TokEnd
-			ti.bracket = bracket
-			// ti.meta undefined
-			res.tokWr++
-			if res.scn.Depth() < 1 {
-				res.scn.Reset()
-			}
+			res.toks = append(res.toks, pullTok{
+				tok:     TokEnd,
+				bracket: bracket,
+			})
 		},
 		func(isMeta bool, atom []byte, quoted bool) {
-			ti := &res.toks[res.tokWr]
-			ti.tok = TokAtom
-			ti.meta = isMeta
-			// ti.bracket undefined
-			var sb strings.Builder
-			sb.Write(atom)
-			res.Atom = sb.String()
-			res.WasQuot = quoted
-			res.tokWr++
+			start := len(res.atoms)
+			res.atoms = append(res.atoms, atom...)
+			res.toks = append(res.toks, pullTok{
+				tok:     TokAtom,
+				meta:    isMeta,
+				quoted:  quoted,
+				raw:     res.scn.IsRaw(),
+				atom:    start,
+				atomEnd: len(res.atoms),
+			})
 		})
-	res.scn = scn
+	res.cmtFn = func(text []byte, brace byte) {
+		start := len(res.atoms)
+		res.atoms = append(res.atoms, text...)
+		res.toks = append(res.toks, pullTok{
+			tok:     TokComment,
+			bracket: brace,
+			atom:    start,
+			atomEnd: len(res.atoms),
+		})
+	}
+	return res
+}
+
+// pullKeep is the number of already pulled tokens that are kept in the queue
+// when it is compacted. One for LastToken and one more to support Unread.
+const pullKeep = 2
+
+// compact drops tokens from the queue that were pulled and are no longer
+// needed.
+func (p *PullParser) compact() {
+	drop := p.tokRd - pullKeep
+	if drop <= 0 {
+		return
+	}
+	keep := p.toks[drop:]
+	abase := len(p.atoms)
+	for i := range keep {
+		if keep[i].tok&(TokAtom|TokComment) != 0 {
+			abase = keep[i].atom
+			break
+		}
+	}
+	n := copy(p.toks, keep)
+	p.toks = p.toks[:n]
+	p.tokRd -= drop
+	if abase > 0 {
+		n = copy(p.atoms, p.atoms[abase:])
+		p.atoms = p.atoms[:n]
+		for i := range p.toks {
+			if p.toks[i].tok&(TokAtom|TokComment) != 0 {
+				p.toks[i].atom -= abase
+				p.toks[i].atomEnd -= abase
+			}
+		}
+	}
+}
+
+// fill reads and scans input until at least one more token is queued.
+func (p *PullParser) fill() error {
+	p.compact()
+	have := len(p.toks)
+	for len(p.toks) == have {
+		if err := p.read(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// read reads and scans one block of input, or it queues TokEOI at the end of
+// input. Scan errors are also reported with TokEOI after all tokens that were
+// scanned before the error.
+func (p *PullParser) read() error {
+	if p.eoi {
+		p.toks = append(p.toks, pullTok{tok: TokEOI})
+		return nil
+	}
+	n, err := p.rd.Read(p.buf)
+	if n > 0 {
+		p.scn.Limits = p.Limits
+		p.scn.Comments = p.Comments
+		p.scn.Escapes = p.Escapes
+		p.scn.UTF8 = p.UTF8
+		p.scn.CheckUTF8 = p.CheckUTF8
+		p.scn.RawAtoms = p.RawAtoms
+		if p.PullComments {
+			p.scn.Comment = p.cmtFn
+		} else {
+			p.scn.Comment = nil
+		}
+		if serr := p.scn.Scan(p.buf[:n]); serr != nil {
+			// deliver the tokens scanned before the error first
+			p.eoi = true
+			p.eoiErr = serr
+			p.toks = append(p.toks, pullTok{tok: TokEOI})
+			return nil
+		}
+	}
+	switch {
+	case err == io.EOF:
+		p.eoi = true
+		p.eoiErr = p.scn.Finish()
+		p.toks = append(p.toks, pullTok{tok: TokEOI})
+	case err != nil:
+		return err
+	}
+	return nil
+}
+
+// pull moves to the next token without setting the Atom field.
+func (p *PullParser) pull() (t *pullTok, err error) {
+	if p.tokRd >= len(p.toks) {
+		if err = p.fill(); err != nil {
+			return nil, err
+		}
+	}
+	t = &p.toks[p.tokRd]
+	p.tokRd++
+	p.unread = true
+	switch t.tok {
+	case TokBegin:
+		p.child()
+		hs := 0
+		if l := len(p.path); l > 0 {
+			hs = p.path[l-1].he
+		}
+		p.path = append(p.path, pathElem{
+			brace: t.bracket,
+			meta:  t.meta,
+			index: -1,
+			hs:    hs,
+			he:    hs,
+		})
+	case TokEnd:
+		if l := len(p.path); l > 0 {
+			p.popped = p.path[l-1]
+			p.path = p.path[:l-1]
+		}
+	case TokAtom:
+		p.WasQuot = t.quoted
+		if e := p.child(); e != nil && e.index == 0 {
+			p.heads = append(p.heads[:e.hs], p.atoms[t.atom:t.atomEnd]...)
+			e.he = len(p.heads)
+			e.hasHead = true
+		}
+	case TokEOI:
+		err, p.eoiErr = p.eoiErr, nil
+		p.lastErr = err
+	}
+	return t, err
+}
+
+// child counts a new child in the innermost open sequence, if any, and
+// returns that sequence's path element.
+func (p *PullParser) child() *pathElem {
+	l := len(p.path)
+	if l == 0 {
+		return nil
+	}
+	e := &p.path[l-1]
+	e.index++
+	return e
+}
+
+// unchild reverts child.
+func (p *PullParser) unchild() {
+	if l := len(p.path); l > 0 {
+		e := &p.path[l-1]
+		if e.index--; e.index < 0 {
+			e.hasHead = false
+			e.he = e.hs
+		}
+	}
+}
+
+// Depth returns the number of sequences that are open after the last token
+// pulled with Next.
+func (p *PullParser) Depth() int { return len(p.path) }
+
+// Path returns the stack of sequences that are open after the last token
+// pulled with Next, outermost first. If the last token was TokBegin, the
+// last element of the path is the sequence that was just opened.
+func (p *PullParser) Path() Path {
+	if len(p.path) == 0 {
+		return nil
+	}
+	res := make(Path, len(p.path))
+	for i := range p.path {
+		e := &p.path[i]
+		res[i] = PathElem{
+			Brace:   e.brace,
+			Meta:    e.meta,
+			Index:   e.index,
+			HasHead: e.hasHead,
+		}
+		if e.hasHead {
+			res[i].Head = string(p.heads[e.hs:e.he])
+		}
+	}
 	return res
 }
 
 func (p *PullParser) Next() (res Token, err error) {
-	if p.tokRd < p.tokWr {
-		res = p.toks[p.tokRd].tok
-		p.tokRd++
-		return res, nil
-	}
-	p.tokWr = 0
-	p.tokRd = 0
-	for p.tokRd >= p.tokWr {
-		err := p.read()
-		if err == io.EOF {
-			err = p.scn.Finish()
-			if p.tokWr >= len(p.toks) {
-				panic("assert failed")
+	t, err := p.pull()
+	if t == nil {
+		return TokEOI, err
+	}
+	if t.tok&(TokAtom|TokComment) != 0 {
+		p.Atom = p.atomStr(t)
+	}
+	return t.tok, err
+}
+
+// Tokens returns an iterator over the tokens pulled from p with Next. The
+// iteration ends before TokEOI unless input ends with an error. When an error
+// occurs, it is yielded together with the token and the iteration ends.
+// The PullParser may be used to access the current token or to pull more
+// tokens within the loop.
+func (p *PullParser) Tokens() iter.Seq2[Token, error] {
+	return func(yield func(Token, error) bool) {
+		for {
+			tok, err := p.Next()
+			switch {
+			case err != nil:
+				yield(tok, err)
+				return
+			case tok == TokEOI:
+				return
 			}
-			p.toks[p.tokWr].tok = TokEOI
-			p.tokWr++
-			if p.tokRd >= p.tokWr {
-				panic("assert failed")
+			if !yield(tok, nil) {
+				return
 			}
-			res = p.toks[p.tokRd].tok
-			p.tokRd++
+		}
+	}
+}
+
+func (p *PullParser) atomStr(t *pullTok) string {
+	if p.Intern == nil {
+		return string(p.atoms[t.atom:t.atomEnd])
+	}
+	return p.Intern(p.atoms[t.atom:t.atomEnd])
+}
+
+// TokenInfo describes a token that is looked ahead with Peek or PeekN.
+type TokenInfo struct {
+	Token Token
+	// Brace is the opening brace of TokBegin and the closing brace of TokEnd
+	Brace  byte
+	Meta   bool
+	Quoted bool
+	// Raw is set for raw atoms, see RawStart. Raw atoms are also Quoted.
+	Raw bool
+	// Atom is only set for TokAtom and TokComment
+	Atom string
+}
+
+// Peek returns the token that will be returned by the next call to Next
+// without consuming it.
+func (p *PullParser) Peek() (TokenInfo, error) { return p.PeekN(1) }
+
+// PeekN returns the k-th token that will be returned by subsequent calls to
+// Next without consuming any token, i.e. PeekN(1) is the same as Peek. At the
+// end of input PeekN returns TokEOI for all k.
+func (p *PullParser) PeekN(k int) (res TokenInfo, err error) {
+	if k < 1 {
+		return res, fmt.Errorf("xsx peek: illegal lookahead %d", k)
+	}
+	// do not compact the queue here to keep AtomBytes valid
+	for p.tokRd+k > len(p.toks) {
+		if err = p.read(); err != nil {
 			return res, err
-		} else if err != nil {
-			return TokEOI, err
-		} else if err = p.scn.Scan(p.buf); err != nil {
-			return TokEOI, err
 		}
 	}
-	res = p.toks[p.tokRd].tok
-	p.tokRd++
-	return res, nil
+	t := &p.toks[p.tokRd+k-1]
+	if t.tok == TokEOI {
+		err = p.eoiErr
+	}
+	return p.tokenInfo(t), err
+}
+
+func (p *PullParser) tokenInfo(t *pullTok) TokenInfo {
+	res := TokenInfo{
+		Token:  t.tok,
+		Brace:  t.bracket,
+		Meta:   t.meta,
+		Quoted: t.quoted,
+		Raw:    t.raw,
+	}
+	if t.tok&(TokAtom|TokComment) != 0 {
+		res.Atom = p.atomStr(t)
+	}
+	return res
+}
+
+// ErrUnread is returned by Unread if there is no token to unread.
+var ErrUnread = errors.New("xsx pull: cannot unread token")
+
+// Unread pushes the last token back such that the next call to Next returns
+// it again. Only one token can be unread between two calls to Next.
+func (p *PullParser) Unread() error {
+	if !p.unread || p.tokRd <= 0 {
+		return ErrUnread
+	}
+	p.unread = false
+	p.tokRd--
+	switch p.toks[p.tokRd].tok {
+	case TokBegin:
+		p.path = p.path[:len(p.path)-1]
+		p.unchild()
+	case TokEnd:
+		p.path = append(p.path, p.popped)
+	case TokAtom:
+		p.unchild()
+	case TokEOI:
+		p.eoiErr = p.lastErr
+	}
+	if p.tokRd > 0 {
+		if t := &p.toks[p.tokRd-1]; t.tok&(TokAtom|TokComment) != 0 {
+			p.Atom = p.atomStr(t)
+			p.WasQuot = t.quoted
+		}
+	}
+	return nil
+}
+
+// AtomBytes returns the text of the last atom or comment pulled by Next. The
+// returned slice must not be modified and is only valid until the next call
+// to Next.
+func (p *PullParser) AtomBytes() []byte {
+	if p.tokRd <= 0 {
+		return nil
+	}
+	if t := &p.toks[p.tokRd-1]; t.tok&(TokAtom|TokComment) != 0 {
+		return p.atoms[t.atom:t.atomEnd]
+	}
+	return nil
 }
 
 func (p *PullParser) LastToken() Token {
 	if p.tokRd <= 0 {
-		return 0
+		return noToken
 	}
 	return p.toks[p.tokRd-1].tok
 }
@@ -148,6 +503,16 @@ func (p *PullParser) WasMeta() bool {
 	return p.toks[p.tokRd-1].meta
 }
 
+// WasRaw reports whether the last atom pulled by Next was a raw atom, see
+// RawStart. Raw atoms are also reported as quoted with WasQuot.
+func (p *PullParser) WasRaw() bool {
+	if p.tokRd <= 0 {
+		return false
+	}
+	t := &p.toks[p.tokRd-1]
+	return t.tok == TokAtom && t.raw
+}
+
 // SkipMeta skips all tokens that are part of the current meta XSX, if so.
 // If the last token was not meta, nothing is skipped. Otherwise SkipMeta stops
 // at the last token of the current meta.
@@ -155,29 +520,67 @@ func (p *PullParser) SkipMeta() (err error) {
 	if !p.WasMeta() {
 		return nil
 	}
+	return p.SkipCurrent()
+}
+
+// SkipCurrent skips the rest of the expression that was started with the last
+// token, i.e. nothing is skipped for an atom or comment and for a begin token
+// all tokens up to and including the matching end token are skipped. Errors
+// in the skipped input are returned as they are, e.g. a ScanError of kind
+// ScanTruncated if input ends before the expression is complete. Only if the
+// end of input was already reported without error, SkipCurrent returns
+// PullTruncated.
+func (p *PullParser) SkipCurrent() error {
 	switch p.LastToken() {
-	case TokAtom:
+	case TokAtom, TokComment:
 		return nil
 	case TokBegin:
-		depth := 1
-		for depth > 0 {
-			tok, err := p.Next()
-			if err != nil {
+		for depth := 1; depth > 0; {
+			t, err := p.pull()
+			switch {
+			case err != nil:
 				return err
+			case t.tok == TokEOI:
+				return PullTruncated
 			}
-			switch tok {
+			switch t.tok {
 			case TokBegin:
 				depth++
 			case TokEnd:
 				depth--
-			case TokEOI:
-				return PullEOI
 			}
 		}
 		return nil
+	case TokEOI:
+		return PullEOI
 	default:
-		panic("unreachable code")
+		return fmt.Errorf("xsx pull: cannot skip from %s token", p.LastToken())
+	}
+}
+
+// SkipNext skips the next complete expression, i.e. an atom or a sequence
+// including all its nested expressions. Comments before the expression are
+// skipped too.
+func (p *PullParser) SkipNext() error {
+	t, err := p.pull()
+	for t != nil && err == nil && t.tok == TokComment {
+		t, err = p.pull()
+	}
+	if t == nil {
+		return err
+	}
+	switch {
+	case t.tok == TokEOI:
+		if err != nil {
+			return err
+		}
+		return PullEOI
+	case err != nil:
+		return err
+	case t.tok == TokEnd:
+		return fmt.Errorf("xsx pull: no expression to skip before end token")
 	}
+	return p.SkipCurrent()
 }
 
 func (p *PullParser) WasAny(joinToken Token) Token {
@@ -190,6 +593,8 @@ const (
 	PullEOI Unexpected = iota
 	PullMeta
 	PullNoMeta
+	// PullTruncated is returned when input ends inside of a sequence.
+	PullTruncated
 )
 
 func (err Unexpected) Error() string {
@@ -198,6 +603,10 @@ func (err Unexpected) Error() string {
 		return "pulled end of input"
 	case PullMeta:
 		return "pulled meta token"
+	case PullNoMeta:
+		return "pulled non-meta token"
+	case PullTruncated:
+		return "pulled end of input inside sequence"
 	}
 	panic("xsx pulled unknown unexpected error")
 }
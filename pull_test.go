@@ -3,8 +3,11 @@ package xsx
 import (
 	"bufio"
 	"bytes"
+	"errors"
 	"fmt"
+	"strconv"
 	"testing"
+	"unsafe"
 
 	"github.com/stvp/assert"
 )
@@ -120,3 +123,287 @@ func ExamplePullParser_WasAny() {
 	// Last Token: begin
 	// Last Token: <no token>
 }
+
+func pullAll(t *testing.T, pp *PullParser) string {
+	var sb bytes.Buffer
+	for {
+		tok, err := pp.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch tok {
+		case TokEOI:
+			return sb.String()
+		case TokBegin:
+			fmt.Fprintf(&sb, "%t%c ", pp.WasMeta(), pp.LastBrace())
+		case TokEnd:
+			fmt.Fprintf(&sb, "%c ", pp.LastBrace())
+		case TokAtom:
+			fmt.Fprintf(&sb, "%t[%s]%t ", pp.WasMeta(), pp.Atom, pp.WasQuot)
+		}
+	}
+}
+
+func TestPullParser_blockSizes(t *testing.T) {
+	const txt = `(foo "bar baz" \quux [\(1 2 "drei\"") 4711] {ä ö ü} \\ \"x")`
+	expect := pullAll(t, NewPullParser(bytes.NewReader([]byte(txt))))
+	for sz := 1; sz <= len(txt); sz++ {
+		pp := NewPullParserSize(bytes.NewReader([]byte(txt)), sz)
+		if got := pullAll(t, pp); got != expect {
+			t.Fatalf("block size %d:\n%s\n%s", sz, expect, got)
+		}
+	}
+}
+
+func TestPullParser_truncated(t *testing.T) {
+	pp := NewPullParser(bytes.NewReader([]byte("(foo")))
+	assertNextTok(t, TokBegin, pp, '(', false)
+	assertNextTok(t, TokAtom, pp, "foo", false, false)
+	tok, err := pp.Next()
+	assert.Equal(t, TokEOI, tok)
+	assert.True(t, errors.Is(err, ScanTruncated))
+	assert.Equal(t, TokEOI, pp.LastToken())
+}
+
+func ExamplePullParser_AtomBytes() {
+	pp := NewPullParser(bytes.NewReader([]byte(`(set x 1) (set y 2) (get x)`)))
+	pp.Intern = InternNone
+	sets := 0
+	for tok, _ := pp.Next(); tok != TokEOI; tok, _ = pp.Next() {
+		if tok == TokAtom && bytes.Equal(pp.AtomBytes(), []byte("set")) {
+			sets++
+		}
+	}
+	fmt.Println("sets:", sets, "atom:", pp.Atom == "")
+	// Output:
+	// sets: 2 atom: true
+}
+
+func TestPullParser_Intern(t *testing.T) {
+	in := NewInterner(0)
+	pp := NewPullParser(bytes.NewReader([]byte(`foo bar foo`)))
+	pp.Intern = in.Intern
+	foo1, _ := pp.NextAtom(AllowMeta)
+	pp.Next()
+	foo2, _ := pp.NextAtom(AllowMeta)
+	assert.Equal(t, "foo", foo1)
+	assert.Equal(t, "foo", foo2)
+	assert.Equal(t, 2, in.Len())
+	if unsafe.StringData(foo1) != unsafe.StringData(foo2) {
+		t.Error("interned atoms do not share memory")
+	}
+}
+
+func TestInterner_max(t *testing.T) {
+	var in Interner
+	for i := range DefaultInternMax + 10 {
+		in.Intern([]byte(strconv.Itoa(i)))
+	}
+	assert.Equal(t, DefaultInternMax, in.Len())
+	in = Interner{Max: 2}
+	for _, a := range []string{"a", "b", "c", "a"} {
+		assert.Equal(t, a, in.Intern([]byte(a)))
+	}
+	assert.Equal(t, 2, in.Len())
+}
+
+func TestPullParser_Peek(t *testing.T) {
+	pp := NewPullParserSize(bytes.NewReader([]byte(`(foo \[bar])`)), 2)
+	ti, err := pp.PeekN(3)
+	assert.Nil(t, err)
+	assert.Equal(t, TokenInfo{Token: TokBegin, Brace: '[', Meta: true}, ti)
+	ti, err = pp.Peek()
+	assert.Nil(t, err)
+	assert.Equal(t, TokenInfo{Token: TokBegin, Brace: '('}, ti)
+	assertNextTok(t, TokBegin, pp, '(', false)
+	ti, _ = pp.Peek()
+	assert.Equal(t, TokenInfo{Token: TokAtom, Atom: "foo"}, ti)
+	ti, _ = pp.PeekN(6)
+	assert.Equal(t, TokEOI, ti.Token)
+	ti, _ = pp.PeekN(42)
+	assert.Equal(t, TokEOI, ti.Token)
+	assertNextTok(t, TokAtom, pp, "foo", false, false)
+	assertNextTok(t, TokBegin, pp, '[', true)
+}
+
+func TestPullParser_Unread(t *testing.T) {
+	pp := NewPullParserSize(bytes.NewReader([]byte(`foo "bar" baz`)), 1)
+	assert.Equal(t, ErrUnread, pp.Unread())
+	assertNextTok(t, TokAtom, pp, "foo", false, false)
+	assertNextTok(t, TokAtom, pp, "bar", false, true)
+	assert.Nil(t, pp.Unread())
+	assertThisTok(t, TokAtom, pp, "foo", false, false)
+	assert.Equal(t, ErrUnread, pp.Unread())
+	assertNextTok(t, TokAtom, pp, "bar", false, true)
+	assertNextTok(t, TokAtom, pp, "baz", false, false)
+	assertNextTok(t, TokEOI, pp)
+	assert.Nil(t, pp.Unread())
+	assertThisTok(t, TokAtom, pp, "baz", false, false)
+	assertNextTok(t, TokEOI, pp)
+}
+
+func TestPullParser_AtomBytesLookahead(t *testing.T) {
+	pp := NewPullParserSize(bytes.NewReader([]byte("aaa bbb ccc ddd eee fff ggg")), 4)
+	for range 3 {
+		_, err := pp.Next()
+		assert.Nil(t, err)
+	}
+	b := pp.AtomBytes()
+	assert.Equal(t, "ccc", string(b))
+	ti, err := pp.PeekN(3)
+	assert.Nil(t, err)
+	assert.Equal(t, "fff", ti.Atom)
+	assert.Equal(t, "ccc", string(b))
+	ti, _ = pp.Peek()
+	assert.Equal(t, "ddd", ti.Atom)
+	assert.Equal(t, "ccc", string(b))
+	assert.Nil(t, pp.Unread())
+	assert.Equal(t, "bbb", string(pp.AtomBytes()))
+	ti, _ = pp.PeekN(5)
+	assert.Equal(t, "ggg", ti.Atom)
+	assert.Equal(t, "ccc", string(b))
+}
+
+func TestPullParser_UnreadErrorEOI(t *testing.T) {
+	pp := NewPullParser(bytes.NewReader([]byte(`(foo`)))
+	assertNextTok(t, TokBegin, pp, '(', false)
+	assertNextTok(t, TokAtom, pp, "foo", false, false)
+	tok, err := pp.Next()
+	assert.Equal(t, TokEOI, tok)
+	assert.True(t, errors.Is(err, ScanTruncated), err)
+	assert.Nil(t, pp.Unread())
+	ti, err := pp.Peek()
+	assert.Equal(t, TokEOI, ti.Token)
+	assert.True(t, errors.Is(err, ScanTruncated), err)
+	tok, err = pp.Next()
+	assert.Equal(t, TokEOI, tok)
+	assert.True(t, errors.Is(err, ScanTruncated), err)
+}
+
+func TestPullParser_SkipCurrent(t *testing.T) {
+	pp := NewPullParser(bytes.NewReader([]byte(`foo (a [b {c}] \d) bar`)))
+	assertNextTok(t, TokAtom, pp, "foo", false, false)
+	assert.Nil(t, pp.SkipCurrent())
+	assertNextTok(t, TokBegin, pp, '(', false)
+	assert.Nil(t, pp.SkipCurrent())
+	assertThisTok(t, TokEnd, pp, ')')
+	assertNextTok(t, TokAtom, pp, "bar", false, false)
+	assertNextTok(t, TokEOI, pp)
+	assert.Equal(t, PullEOI, pp.SkipCurrent())
+}
+
+func TestPullParser_SkipNext(t *testing.T) {
+	pp := NewPullParser(bytes.NewReader([]byte(`(unknown (x y) z) known`)))
+	assert.Nil(t, pp.SkipNext())
+	assertNextTok(t, TokAtom, pp, "known", false, false)
+	assert.Equal(t, PullEOI, pp.SkipNext())
+}
+
+func TestPullParser_SkipTruncated(t *testing.T) {
+	pp := NewPullParser(bytes.NewReader([]byte(`\(foo [bar`)))
+	assertNextTok(t, TokBegin, pp, '(', true)
+	err := pp.SkipMeta()
+	assert.True(t, errors.Is(err, ScanTruncated), err)
+	pp = NewPullParser(bytes.NewReader([]byte(`(foo [bar`)))
+	err = pp.SkipNext()
+	assert.True(t, errors.Is(err, ScanTruncated), err)
+}
+
+func TestPullParser_SkipMalformed(t *testing.T) {
+	pp := NewPullParser(bytes.NewReader([]byte(`(a (b] c)`)))
+	assertNextTok(t, TokBegin, pp, '(', false)
+	err := pp.SkipCurrent()
+	assert.True(t, errors.Is(err, ScanUnbalanced), err)
+}
+
+func ExamplePullParser_Path() {
+	pp := NewPullParser(bytes.NewReader([]byte(`(server (listen host 8080 oops))`)))
+	for {
+		tok, err := pp.Next()
+		if err != nil || tok == TokEOI {
+			break
+		}
+		if tok == TokAtom && pp.Atom == "oops" {
+			path := pp.Path()
+			fmt.Printf("depth %d: in %s element %d\n",
+				pp.Depth(),
+				path,
+				path[len(path)-1].Index)
+		}
+	}
+	// Output:
+	// depth 2: in (server (listen …)) element 3
+}
+
+func TestPullParser_Path(t *testing.T) {
+	pp := NewPullParser(bytes.NewReader([]byte(`a ((x) "h d" \[b]) c`)))
+	assertNextTok(t, TokAtom, pp, "a", false, false)
+	assert.Equal(t, 0, pp.Depth())
+	assert.Nil(t, pp.Path())
+	assertNextTok(t, TokBegin, pp, '(', false)
+	assertNextTok(t, TokBegin, pp, '(', false)
+	assertNextTok(t, TokAtom, pp, "x", false, false)
+	assert.Equal(t, "(… (x …))", pp.Path().String())
+	assertNextTok(t, TokEnd, pp, ')')
+	assert.Equal(t, 1, pp.Depth())
+	assert.Nil(t, pp.Unread())
+	assert.Equal(t, "(… (x …))", pp.Path().String())
+	assertNextTok(t, TokEnd, pp, ')')
+	assertNextTok(t, TokAtom, pp, "h d", false, true)
+	assert.Equal(t, Path{{Brace: '(', Index: 1}}, pp.Path())
+	assertNextTok(t, TokBegin, pp, '[', true)
+	assert.Equal(t, `(… \[…])`, pp.Path().String())
+	assert.Nil(t, pp.Unread())
+	assert.Equal(t, Path{{Brace: '(', Index: 1}}, pp.Path())
+	assertNextTok(t, TokBegin, pp, '[', true)
+	assert.Nil(t, pp.SkipCurrent())
+	assert.Equal(t, Path{{Brace: '(', Index: 2}}, pp.Path())
+	assertNextTok(t, TokEnd, pp, ')')
+	assertNextTok(t, TokAtom, pp, "c", false, false)
+	assert.Equal(t, 0, pp.Depth())
+}
+
+func TestPullParser_PathHeadUnread(t *testing.T) {
+	pp := NewPullParser(bytes.NewReader([]byte(`("a b" c)`)))
+	assertNextTok(t, TokBegin, pp, '(', false)
+	assertNextTok(t, TokAtom, pp, "a b", false, true)
+	assert.Equal(t, `("a b" …)`, pp.Path().String())
+	assert.Nil(t, pp.Unread())
+	assert.Equal(t, Path{{Brace: '(', Index: -1}}, pp.Path())
+}
+
+func ExamplePullParser_Tokens() {
+	pp := NewPullParser(bytes.NewReader([]byte(`(a \[b]) "c d"`)))
+	for tok, err := range pp.Tokens() {
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		switch tok {
+		case TokAtom:
+			fmt.Println(tok, pp.Atom)
+		default:
+			fmt.Printf("%s %c meta=%t\n", tok, pp.LastBrace(), pp.WasMeta())
+		}
+	}
+	// Output:
+	// begin ( meta=false
+	// atom a
+	// begin [ meta=true
+	// atom b
+	// end ] meta=false
+	// end ) meta=false
+	// atom c d
+}
+
+func TestPullParser_TokensError(t *testing.T) {
+	pp := NewPullParser(bytes.NewReader([]byte(`(a`)))
+	var toks []Token
+	var err error
+	for tok, e := range pp.Tokens() {
+		toks = append(toks, tok)
+		err = e
+	}
+	assert.Equal(t, []Token{TokBegin, TokAtom, TokEOI}, toks)
+	assert.True(t, errors.Is(err, ScanTruncated))
+}
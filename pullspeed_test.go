@@ -0,0 +1,81 @@
+package xsx
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func benchmarkInput() []byte {
+	buf := bytes.NewBuffer(nil)
+	rnd := rand.New(rand.NewSource(4712))
+	for i := 0; i < 2000; i++ {
+		fmt.Fprintf(buf, "(row %d \\{id %x} \"text with \\\"quotes\\\"\" [%d %d %d] %s)\n",
+			i,
+			rnd.Int63(),
+			rnd.Intn(10), rnd.Intn(100), rnd.Intn(1000),
+			strings.Repeat("abcdef", rnd.Intn(5)))
+	}
+	return buf.Bytes()
+}
+
+func BenchmarkPullParser(b *testing.B) {
+	txt := benchmarkInput()
+	b.SetBytes(int64(len(txt)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		pp := NewPullParser(bytes.NewReader(txt))
+		for tok, err := pp.Next(); tok != TokEOI; tok, err = pp.Next() {
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkScanner_Read(b *testing.B) {
+	txt := benchmarkInput()
+	scn := NewScanner(BeginNop, EndNop, AtomNop)
+	b.SetBytes(int64(len(txt)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		scn.Read(bytes.NewReader(txt))
+		scn.Reset()
+	}
+}
+
+func BenchmarkPullParser_intern(b *testing.B) {
+	txt := benchmarkInput()
+	in := NewInterner(1 << 12)
+	b.SetBytes(int64(len(txt)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		pp := NewPullParser(bytes.NewReader(txt))
+		pp.Intern = in.Intern
+		for tok, err := pp.Next(); tok != TokEOI; tok, err = pp.Next() {
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkPullParser_bytes(b *testing.B) {
+	txt := benchmarkInput()
+	b.SetBytes(int64(len(txt)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		pp := NewPullParser(bytes.NewReader(txt))
+		pp.Intern = InternNone
+		for tok, err := pp.Next(); tok != TokEOI; tok, err = pp.Next() {
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
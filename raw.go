@@ -0,0 +1,146 @@
+package xsx
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// RawStart is the byte that starts a raw atom if raw atoms are enabled. A raw
+// atom is written as n ≥ 1 RawStart bytes and a '"', followed by the verbatim
+// atom text, and terminated with a '"' and the same number n of RawStart
+// bytes, e.g.
+//
+//	#"C:\Users\"#  ##"a "# is not the end"##
+//
+// There are no escapes in raw atoms. Because n can be chosen freely, any text
+// can be written as raw atom. Raw atoms are reported as quoted atoms.
+const RawStart = '#'
+
+// IsRaw reports whether the current atom is a raw atom. IsRaw is only
+// meaningful while the Scanner calls its Atom callback.
+func (s *Scanner) IsRaw() bool { return s.raw }
+
+// countRaw returns the number of leading RawStart bytes in txt.
+func countRaw(txt []byte) (n int) {
+	for n < len(txt) && txt[n] == RawStart {
+		n++
+	}
+	return n
+}
+
+// rawStart checks if the unquoted atom in s.atomHead that consists only of
+// RawStart bytes continues as the start of a raw atom in txt. If so, s is
+// switched to scan the raw atom and rawStart returns the number of bytes used
+// from txt. If txt has only RawStart bytes it cannot be decided and ok is
+// false.
+func (s *Scanner) rawStart(txt []byte) (used int, ok bool) {
+	if countRaw(s.atomHead) < len(s.atomHead) {
+		return 0, true
+	}
+	n := countRaw(txt)
+	switch {
+	case n == len(txt):
+		return 0, false
+	case txt[n] != '"':
+		return 0, true
+	}
+	s.rawN = len(s.atomHead) + n
+	s.rawClose = 0
+	s.atomHead = s.atomHead[:0]
+	s.aheadMode = aheadRaw
+	return n + 1, true
+}
+
+// findRawEnd returns the index of the '"' that starts the terminator of a raw
+// atom with n RawStart bytes in txt or -1 if the terminator is not in txt.
+func findRawEnd(txt []byte, n int) int {
+	for i := 0; ; {
+		q := bytes.IndexByte(txt[i:], '"')
+		if q < 0 {
+			return -1
+		}
+		i += q + 1
+		if len(txt)-i < n {
+			return -1
+		}
+		if countRaw(txt[i:i+n]) == n {
+			return i - 1
+		}
+	}
+}
+
+// rawScan appends the text of the current raw atom from txt to s.atomHead. It
+// returns the number of bytes used from txt and true if the atom's terminator
+// was found. A partial terminator at the end of txt is kept in s.rawClose.
+func (s *Scanner) rawScan(txt []byte) (used int, done bool) {
+	for used < len(txt) {
+		switch {
+		case s.rawClose == 0:
+			q := bytes.IndexByte(txt[used:], '"')
+			if q < 0 {
+				s.atomHead = append(s.atomHead, txt[used:]...)
+				return len(txt), false
+			}
+			s.atomHead = append(s.atomHead, txt[used:used+q]...)
+			used += q + 1
+			s.rawClose = 1
+		case txt[used] == RawStart:
+			used++
+			if s.rawClose++; s.rawClose > s.rawN {
+				s.rawClose = 0
+				return used, true
+			}
+		default:
+			// it was not the terminator but text
+			s.atomHead = append(s.atomHead, '"')
+			for ; s.rawClose > 1; s.rawClose-- {
+				s.atomHead = append(s.atomHead, RawStart)
+			}
+			s.rawClose = 0
+		}
+	}
+	return used, false
+}
+
+func (s *Scanner) callRawAtom(txt []byte, meta bool, atom []byte) error {
+	s.raw = true
+	err := s.callAtom(txt, meta, atom, true)
+	s.raw = false
+	return err
+}
+
+// RawQuoteTo writes str as raw atom to wr using the least number of RawStart
+// bytes that is needed. See also Scanner.RawAtoms.
+func RawQuoteTo(str string, wr io.Writer) (err error) {
+	n := 1
+	for strings.Contains(str, `"`+strings.Repeat(string(RawStart), n)) {
+		n++
+	}
+	fence := strings.Repeat(string(RawStart), n)
+	_, err = io.WriteString(wr, fence+`"`+str+`"`+fence)
+	return err
+}
+
+// PreferRaw reports whether str is better written as raw atom because
+// quoting would need escapes. With ext, str must also not contain characters
+// that are escaped with the extended escape dialect to keep the atom on one
+// line. Printers use PreferRaw to write atoms with QcondRaw.
+func PreferRaw(str string, ext bool) bool {
+	if !strings.ContainsAny(str, "\"\\") {
+		return false
+	}
+	if ext {
+		if !utf8.ValidString(str) {
+			return false
+		}
+		for _, r := range str {
+			if !unicode.IsPrint(r) {
+				return false
+			}
+		}
+	}
+	return true
+}
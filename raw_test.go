@@ -0,0 +1,107 @@
+package xsx
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stvp/assert"
+)
+
+func rawScanner(wr *bytes.Buffer) *Scanner {
+	var s *Scanner
+	s = NewScanner(
+		func(meta bool, brace byte) { fmt.Fprintf(wr, "begin %t %c\n", meta, brace) },
+		func(meta bool, brace byte) { fmt.Fprintf(wr, "end %c\n", brace) },
+		func(meta bool, atom []byte, quoted bool) {
+			start, end := s.Pos()
+			fmt.Fprintf(wr, "atom %t %t %t [%s] %s-%s\n", meta, quoted, s.IsRaw(), atom, start, end)
+		},
+	)
+	s.RawAtoms = true
+	return s
+}
+
+func ExampleRawStart() {
+	var out bytes.Buffer
+	s := rawScanner(&out)
+	mustExample(s.ScanString(`(sh #"echo "\(x)""#
+  \##"a "# b"## # #x)`))
+	fmt.Print(out.String())
+	// Output:
+	// begin false (
+	// atom false false false [sh] 1:2-1:4
+	// atom false true true [echo "\(x)"] 1:5-1:20
+	// atom true true true [a "# b] 2:3-2:16
+	// atom false false false [#] 2:17-2:18
+	// atom false false false [#x] 2:19-2:21
+	// end )
+}
+
+func TestScanner_RawSplit(t *testing.T) {
+	checkSplitInvariant(t, rawScanner, `(#"a"# ##"b"#""c"## \###"""#"##"### ## ##x "q" #"x"#)`)
+}
+
+func TestScanner_RawUnterminated(t *testing.T) {
+	var out bytes.Buffer
+	s := rawScanner(&out)
+	err := s.ScanString(`#"abc"`)
+	assert.True(t, errors.Is(err, ScanUnterminatedQuote), err)
+
+	out.Reset()
+	s = rawScanner(&out)
+	s.RawAtoms = false
+	assert.Nil(t, s.ScanString(`#"a"#`))
+	assert.Equal(t, "atom false false false [#] 1:1-1:2\n"+
+		"atom false true false [a] 1:2-1:5\n"+
+		"atom false false false [#] 1:5-1:6\n",
+		out.String())
+}
+
+func TestRawQuoteTo(t *testing.T) {
+	for _, str := range []string{"", `"`, `"#`, `a"##"#b`, `x"`, "multi\nline"} {
+		var sb strings.Builder
+		assert.Nil(t, RawQuoteTo(str, &sb))
+		var atom []byte
+		s := NewScanner(BeginNop, EndNop, func(_ bool, a []byte, _ bool) {
+			atom = append([]byte(nil), a...)
+		})
+		s.RawAtoms = true
+		assert.Nil(t, s.ScanString(sb.String()), sb.String())
+		assert.Equal(t, str, string(atom), sb.String())
+	}
+}
+
+func TestPrinter_QcondRaw(t *testing.T) {
+	var sb strings.Builder
+	p := Compact(&sb)
+	assert.Nil(t, Write(p, B('(')))
+	for _, a := range []string{"plain", "with space", `C:\tmp\`, `say "hi"`} {
+		assert.Nil(t, p.Atom(a, false, QcondRaw))
+	}
+	assert.Nil(t, p.Atom("raw", false, Qraw))
+	assert.Nil(t, p.End())
+	assert.Equal(t, `(plain "with space" #"C:\tmp\"# #"say "hi""# #"raw"#)`, sb.String())
+
+	sb.Reset()
+	p = Compact(&sb)
+	p.Escapes = true
+	assert.Nil(t, p.Atom("two\nlines \"quoted\"", false, QcondRaw))
+	assert.Equal(t, `"two\nlines \"quoted\""`, sb.String())
+}
+
+func TestPullParser_WasRaw(t *testing.T) {
+	p := NewPullParser(strings.NewReader(`"q" #"r"# p`))
+	p.RawAtoms = true
+	for _, raw := range []bool{false, true, false} {
+		tok, err := p.Next()
+		assert.Nil(t, err)
+		assert.Equal(t, TokAtom, tok)
+		assert.Equal(t, raw, p.WasRaw(), p.Atom)
+	}
+	tok, _ := p.Next()
+	assert.Equal(t, TokEOI, tok)
+	assert.False(t, p.WasRaw())
+}
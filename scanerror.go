@@ -0,0 +1,205 @@
+package xsx
+
+import (
+	"bytes"
+	"fmt"
+	"unicode/utf8"
+)
+
+// ScanErrorKind classifies the errors reported by Scanner. All kinds are
+// errors themselves so that one can check for them with errors.Is, e.g.
+//
+//	errors.Is(err, ScanUnbalanced)
+type ScanErrorKind int
+
+const (
+	// ScanFailed is the kind of errors that are not classified otherwise,
+	// e.g. when Scanner.Read cannot read its input. Then the reader's error
+	// is the ScanError's Reason.
+	ScanFailed ScanErrorKind = iota
+	// ScanUnbalanced means that a closing brace does not match the opening
+	// brace.
+	ScanUnbalanced
+	// ScanUnnested means that a closing brace was found outside of any
+	// sequence.
+	ScanUnnested
+	// ScanUnterminatedQuote means that input ended in a quoted atom.
+	ScanUnterminatedQuote
+	// ScanTruncated means that input ended inside of a sequence.
+	ScanTruncated
+	// ScanCallback means that one of the Scanner's callbacks failed. The
+	// callback's error is the ScanError's Reason.
+	ScanCallback
+	// ScanUnterminatedComment means that input ended in a block comment.
+	ScanUnterminatedComment
+	// ScanLimit means that input exceeds one of the Scanner's Limits. The
+	// ScanError's Reason is a *LimitError.
+	ScanLimit
+	// ScanBadEscape means that a quoted atom contains an illegal escape
+	// sequence. It is only reported if Scanner.Escapes is set.
+	ScanBadEscape
+	// ScanInvalidUTF8 means that input is not valid UTF-8. It is only
+	// reported if Scanner.CheckUTF8 is set.
+	ScanInvalidUTF8
+)
+
+func (k ScanErrorKind) Error() string {
+	switch k {
+	case ScanFailed:
+		return "scanning failed"
+	case ScanUnbalanced:
+		return "unbalanced bracing"
+	case ScanUnnested:
+		return "closing brace outside of sequence"
+	case ScanUnterminatedQuote:
+		return "unterminated quoted atom"
+	case ScanTruncated:
+		return "input ends in nested expression"
+	case ScanCallback:
+		return "scanner callback failed"
+	case ScanUnterminatedComment:
+		return "unterminated block comment"
+	case ScanLimit:
+		return "input exceeds limit"
+	case ScanBadEscape:
+		return "illegal escape sequence"
+	case ScanInvalidUTF8:
+		return "invalid UTF-8"
+	}
+	return fmt.Sprintf("<illegal scan error kind: %d>", int(k))
+}
+
+type ScanError struct {
+	hint    string
+	pos     int64
+	line    int
+	col     int
+	kind    ScanErrorKind
+	msg     string
+	rsn     error
+	snippet string
+}
+
+// Error returns the message prefixed with the position as returned by Where.
+// If available, the Snippet follows on the next lines.
+func (err *ScanError) Error() string {
+	if err.snippet == "" {
+		return err.Where() + ": " + err.msg
+	}
+	return err.Where() + ": " + err.msg + "\n" + err.snippet
+}
+
+// Position returns the byte offset where the error occurred.
+func (err *ScanError) Position() int64 {
+	return err.pos
+}
+
+// Line returns the 1-based line where the error occurred.
+func (err *ScanError) Line() int { return err.line }
+
+// Column returns the 1-based column in runes where the error occurred.
+func (err *ScanError) Column() int { return err.col }
+
+// Kind returns the classification of the error.
+func (err *ScanError) Kind() ScanErrorKind { return err.kind }
+
+func (err *ScanError) Message() string {
+	return err.msg
+}
+
+func (err *ScanError) Reason() error {
+	return err.rsn
+}
+
+// Snippet returns an excerpt of the input line where the error occurred with
+// a second line that puts a caret '^' below the error position. Snippet is
+// empty when the input was no longer available to the Scanner, e.g. when the
+// error was detected by Scanner.Finish.
+func (err *ScanError) Snippet() string { return err.snippet }
+
+// Is makes ScanError match its Kind with errors.Is.
+func (err *ScanError) Is(target error) bool {
+	if k, ok := target.(ScanErrorKind); ok {
+		return k == err.kind
+	}
+	return false
+}
+
+// Unwrap returns the Reason of the error.
+func (err *ScanError) Unwrap() error { return err.rsn }
+
+// Where returns the error's position as "hint:line:column". The source hint
+// is omitted if empty.
+func (err *ScanError) Where() string {
+	if err.hint == "" {
+		return fmt.Sprintf("%d:%d", err.line, err.col)
+	}
+	return fmt.Sprintf("%s:%d:%d", err.hint, err.line, err.col)
+}
+
+// scanError creates a ScanError at position at. If txt is not nil, it is the
+// current chunk of input that starts at offset s.pos.
+func (s *Scanner) scanError(
+	kind ScanErrorKind,
+	at Position,
+	txt []byte,
+	msg string,
+	rsn error,
+) *ScanError {
+	if at = s.resolve(txt, at); !at.IsValid() {
+		at = Position{Offset: at.Offset, Line: 1, Col: 1}
+	}
+	res := &ScanError{
+		hint: s.SrcHint,
+		pos:  at.Offset,
+		line: at.Line,
+		col:  at.Col,
+		kind: kind,
+		msg:  msg,
+		rsn:  rsn,
+	}
+	if off := at.Offset - s.pos; txt != nil && off >= 0 && off <= int64(len(txt)) {
+		res.snippet = snippet(txt, int(off))
+	}
+	return res
+}
+
+const (
+	snippetBefore = 60
+	snippetAfter  = 20
+)
+
+func snippet(txt []byte, off int) string {
+	ls := bytes.LastIndexByte(txt[:off], '\n') + 1
+	if off-ls > snippetBefore {
+		ls = off - snippetBefore
+		for ls < off && !utf8.RuneStart(txt[ls]) {
+			ls++
+		}
+	}
+	le := bytes.IndexByte(txt[off:], '\n')
+	if le < 0 {
+		le = len(txt)
+	} else {
+		le += off
+	}
+	if le-off > snippetAfter {
+		le = off + snippetAfter
+		for le > off && !utf8.RuneStart(txt[le]) {
+			le--
+		}
+	}
+	line := bytes.TrimRight(txt[ls:le], "\r")
+	var sb bytes.Buffer
+	sb.Write(line)
+	sb.WriteByte('\n')
+	for _, r := range string(txt[ls:off]) {
+		if r == '\t' {
+			sb.WriteByte('\t')
+		} else {
+			sb.WriteByte(' ')
+		}
+	}
+	sb.WriteByte('^')
+	return sb.String()
+}
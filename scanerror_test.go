@@ -0,0 +1,101 @@
+package xsx
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestScanError_kinds(t *testing.T) {
+	for _, tc := range []struct {
+		txt  string
+		kind ScanErrorKind
+	}{
+		{"(}", ScanUnbalanced},
+		{"foo)", ScanUnnested},
+		{"\"foo", ScanUnterminatedQuote},
+		{"(foo", ScanTruncated},
+	} {
+		s := NewTestScanner(false)
+		err := s.ScanString(tc.txt)
+		if !errors.Is(err, tc.kind) {
+			t.Errorf("'%s': expected %s, got %v", tc.txt, tc.kind, err)
+		}
+		var scnErr *ScanError
+		if !errors.As(err, &scnErr) {
+			t.Fatalf("'%s': not a scan error: %v", tc.txt, err)
+		}
+		if scnErr.Kind() != tc.kind {
+			t.Errorf("'%s': wrong kind %s", tc.txt, scnErr.Kind())
+		}
+	}
+}
+
+var errTestCallback = errors.New("test callback failed")
+
+type atomFail struct{}
+
+func (atomFail) Begin(isMeta bool, brace byte) error { return nil }
+
+func (atomFail) End(isMeta bool, brace byte) error { return nil }
+
+func (atomFail) Atom(isMeta bool, atom []byte, quoted bool) error {
+	return errTestCallback
+}
+
+func TestScanError_callback(t *testing.T) {
+	s := NewHandlerScanner(atomFail{})
+	err := s.ScanString("(\n  foo)")
+	if !errors.Is(err, ScanCallback) {
+		t.Errorf("expected callback error, got %v", err)
+	}
+	if !errors.Is(err, errTestCallback) {
+		t.Errorf("callback error is not the reason: %v", err)
+	}
+	scnErr := err.(*ScanError)
+	if scnErr.Line() != 2 || scnErr.Column() != 3 {
+		t.Errorf("wrong error location %d:%d", scnErr.Line(), scnErr.Column())
+	}
+}
+
+func TestScanError_read(t *testing.T) {
+	errRead := errors.New("read failed")
+	s := NewTestScanner(false)
+	s.SrcHint = "in"
+	err := s.Read(io.MultiReader(strings.NewReader("(a\nb"), iotest.ErrReader(errRead)))
+	if !errors.Is(err, ScanFailed) || !errors.Is(err, errRead) {
+		t.Fatalf("expected failed read, got %v", err)
+	}
+	if err.Error() != "in:2:2: read failed" {
+		t.Errorf("unexpected error message: '%s'", err)
+	}
+}
+
+func ExampleScanError_Error() {
+	s := NewTestScanner(false)
+	s.SrcHint = "file.xsx"
+	fmt.Println(s.ScanString("(server\n\t(listen 8080]\n)"))
+	s.Reset()
+	fmt.Println(s.ScanString("(server"))
+	// Output:
+	// file.xsx:2:14: unbalanced bracing: ']', expected ')'
+	// 	(listen 8080]
+	// 	            ^
+	// file.xsx:1:8: cannot finish scanning in nested expression
+}
+
+func ExampleScanError_Snippet() {
+	s := NewTestScanner(false)
+	s.SrcHint = "file.xsx"
+	err := s.ScanString("(server\n\t(listen 8080]\n)")
+	scnErr := err.(*ScanError)
+	fmt.Println(scnErr.Where(), scnErr.Kind())
+	fmt.Println(scnErr.Snippet())
+	// Output:
+	// file.xsx:2:14 unbalanced bracing
+	// 	(listen 8080]
+	// 	            ^
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"sync"
+	"unicode/utf8"
 )
 
 const (
@@ -14,29 +15,6 @@ const (
 	MetaStr = string(Meta)
 )
 
-type ScanError struct {
-	hint string
-	pos  int64
-	msg  string
-	rsn  error
-}
-
-func (err *ScanError) Error() string {
-	return fmt.Sprintf("%s@%d:%s", err.hint, err.pos, err.msg)
-}
-
-func (err *ScanError) Position() int64 {
-	return err.pos
-}
-
-func (err *ScanError) Message() string {
-	return err.msg
-}
-
-func (err *ScanError) Reason() error {
-	return err.rsn
-}
-
 // BeginFunc is called by Scanner when an opening bracket is detected.
 type BeginFunc func(isMeta bool, brace byte)
 
@@ -57,26 +35,78 @@ type AtomFunc func(isMeta bool, atom []byte, quoted bool)
 // AtomNop performs No OPeration on atom event.
 func AtomNop(isMeta bool, atom []byte, quoted bool) {}
 
+// Handler receives the events of a Scanner like BeginFunc, EndFunc and
+// AtomFunc do. If one of the Handler methods returns an error, the Scanner
+// stops scanning and returns a ScanError of kind ScanCallback with the
+// Handler's error as reason.
+type Handler interface {
+	Begin(isMeta bool, brace byte) error
+	End(isMeta bool, brace byte) error
+	Atom(isMeta bool, atom []byte, quoted bool) error
+}
+
 type atomHeadMode int
 
 const (
 	aheadPlain atomHeadMode = iota
 	aheadQuote
 	aheadEsc
+	aheadRaw
 )
 
+// Scanner reports XSX events either to its callback functions Begin, End and
+// Atom or to its Handler, if set. Panics in callbacks are not recovered.
 type Scanner struct {
-	Begin     BeginFunc
-	End       EndFunc
-	Atom      AtomFunc
+	Begin   BeginFunc
+	End     EndFunc
+	Atom    AtomFunc
+	Handler Handler
+	// Comments enables the comment syntax, see CommentStart. Comments are
+	// skipped unless Comment is set or Handler is a CommentHandler.
+	Comments bool
+	Comment  CommentFunc
+	// Escapes enables the extended escape dialect in quoted atoms. Besides
+	// '\"' and '\\' the escapes '\n', '\t', '\r', '\0', '\xHH' with two hex
+	// digits for a byte and '\u{H…}' with up to six hex digits for a Unicode
+	// code point are decoded. All other escapes are reported as ScanError of
+	// kind ScanBadEscape. Without Escapes, '\' makes any following byte part
+	// of the atom. See also EscapeExtTo.
+	Escapes bool
+	// UTF8 makes the Scanner recognize all Unicode white space runes, e.g.
+	// U+00A0 or U+2028, as separators. Without UTF8 the Scanner works on bytes
+	// and the single bytes 0x85 and 0xA0 are white space too, which splits
+	// UTF-8 encoded runes like 'à' (0xC3 0xA0).
+	UTF8 bool
+	// CheckUTF8 makes the Scanner report input that is not valid UTF-8 as
+	// ScanError of kind ScanInvalidUTF8.
+	CheckUTF8 bool
+	// RawAtoms enables raw atoms, see RawStart.
+	RawAtoms bool
+	Limits
 	SrcHint   string
 	WsBuf     *bytes.Buffer
 	pos       int64
 	meta      bool
 	nest      []nesting
+	metaAt    int // 1-based index in nest of the outermost meta sequence
 	atomHead  []byte
 	aheadMode atomHeadMode
 	qatomBuf  bytes.Buffer
+	escBuf    []byte
+	cur       Position // position of the next byte not yet counted
+	tokStart  Position
+	tokEnd    Position
+	metaStart Position
+	aheadPos  Position // start of the atom kept in atomHead
+	chunk     []byte   // input passed to Scan while scanning
+	checkAtom bool     // atoms are not passed directly to the Atom callback
+	cmt       commentState
+	u8        [utf8.UTFMax]byte // incomplete rune at the end of the last chunk
+	u8n       int
+	u8Pos     Position // start of the incomplete rune in u8
+	rawN      int      // number of RawStart bytes of the current raw atom
+	rawClose  int      // length of a partial raw atom terminator
+	raw       bool     // the current atom is a raw atom
 }
 
 type nesting struct {
@@ -92,80 +122,138 @@ func NewScanner(begin BeginFunc, end EndFunc, atom AtomFunc) *Scanner {
 	}
 }
 
+// NewHandlerScanner creates a Scanner that reports events to h.
+func NewHandlerScanner(h Handler) *Scanner {
+	return &Scanner{Handler: h}
+}
+
 func (s *Scanner) Complete() bool {
-	return s.atomHead == nil && !s.meta && len(s.nest) == 0
+	return s.atomHead == nil && !s.meta && len(s.nest) == 0 && s.cmt.mode == cmtNone
 }
 
+// Finish signals the end of input to the Scanner. A pending unquoted atom
+// is reported before Finish checks that no sequence is left open.
 func (s *Scanner) Finish() (err error) {
-	if len(s.nest) > 0 {
-		return &ScanError{
-			hint: s.SrcHint,
-			pos:  s.pos,
-			msg:  "cannot finish scanning in nested expression",
+	s.checkAtom = s.Handler != nil || s.MaxAtomLen > 0
+	if s.CheckUTF8 && s.u8n > 0 {
+		return s.scanError(ScanInvalidUTF8, s.u8Pos, nil,
+			"input ends in incomplete UTF-8 sequence", nil)
+	}
+	if s.cmt.mode != cmtNone {
+		if err = s.finishComment(); err != nil {
+			return err
 		}
 	}
 	if s.atomHead != nil {
-		if s.aheadMode != aheadPlain {
-			return &ScanError{
-				hint: s.SrcHint,
-				pos:  s.pos,
-				msg:  "unterminated quoted atom",
-			}
+		switch s.aheadMode {
+		case aheadPlain:
+		case aheadRaw:
+			return s.scanError(ScanUnterminatedQuote, s.cur, nil,
+				"unterminated raw atom", nil)
+		default:
+			return s.scanError(ScanUnterminatedQuote, s.cur, nil,
+				"unterminated quoted atom", nil)
 		}
-		defer func() {
-			if p := recover(); p != nil {
-				switch x := p.(type) {
-				case *ScanError:
-					err = x
-				case error:
-					err = &ScanError{hint: s.SrcHint, pos: s.pos, msg: x.Error(), rsn: x}
-				default:
-					err = &ScanError{
-						hint: s.SrcHint,
-						pos:  s.pos,
-						msg:  fmt.Sprintf("%T:[%v]", p, p),
-					}
-				}
-			}
-		}()
-		s.Atom(s.meta, s.atomHead, s.aheadMode == aheadQuote)
+		s.tokStart, s.tokEnd = s.aheadPos, s.cur
+		err = s.callAtom(nil, s.meta, s.atomHead, false)
 		s.meta = false
 		s.atomHead = nil
 	} else if s.meta {
-		s.Atom(false, metaAtom, false)
+		s.tokStart, s.tokEnd = s.metaStart, s.metaStart.next()
+		err = s.callAtom(nil, false, metaAtom, false)
+	}
+	if err != nil {
+		return err
+	}
+	if len(s.nest) > 0 {
+		return s.scanError(ScanTruncated, s.cur, nil,
+			"cannot finish scanning in nested expression", nil)
 	}
 	return nil
 }
 
 func (s *Scanner) Depth() int { return len(s.nest) }
 
+// Pos returns the start and end position of the current token. The end
+// position is the position right after the token. Pos is only meaningful
+// while the Scanner calls one of its callbacks. The start of a meta token is
+// the position of its Meta prefix. Positions are counted over all input
+// passed to Scan since the last Reset.
+func (s *Scanner) Pos() (start, end Position) {
+	s.tokStart = s.resolve(s.chunk, s.tokStart)
+	s.tokEnd = s.resolve(s.chunk, s.tokEnd)
+	return s.tokStart, s.tokEnd
+}
+
 func (s *Scanner) Reset() {
 	s.pos = 0
+	s.cur = Position{Line: 1, Col: 1}
 	s.meta = false
 	if s.nest != nil {
 		s.nest = s.nest[:0]
 	}
+	s.metaAt = 0
 	s.atomHead = nil
+	s.cmt.mode = cmtNone
+	s.u8n = 0
 }
 
 func (s *Scanner) push(meta bool, closing byte) {
 	s.nest = append(s.nest, nesting{meta, closing})
+	if meta && s.metaAt == 0 {
+		s.metaAt = len(s.nest)
+	}
 }
 
-func (s *Scanner) pop(found byte) (meta bool) {
-	if end := len(s.nest); end > 0 {
-		end--
-		n := s.nest[end]
-		s.nest = s.nest[:end]
-		if n.cbrace != found {
-			panic(fmt.Errorf("unbalanced bracing: '%c', expected '%c'",
-				found, n.cbrace))
-		}
-		return n.meta
-	} else {
-		panic(fmt.Errorf("%s:%d:poping '%c' from unnested",
-			s.SrcHint, s.pos, found))
+// checkBegin checks the nesting limits before a sequence is opened.
+func (s *Scanner) checkBegin(txt []byte) error {
+	if s.MaxDepth > 0 && len(s.nest) >= s.MaxDepth {
+		return s.limitError(txt, s.tokStart, "depth", s.MaxDepth)
+	}
+	// the outermost meta sequence has meta depth 1
+	if s.MaxMetaDepth > 0 && s.metaAt > 0 && len(s.nest)+2-s.metaAt > s.MaxMetaDepth {
+		return s.limitError(txt, s.tokStart, "meta depth", s.MaxMetaDepth)
+	}
+	return nil
+}
+
+func (s *Scanner) limitError(txt []byte, at Position, limit string, max int) error {
+	rsn := &LimitError{Limit: limit, Max: int64(max)}
+	return s.scanError(ScanLimit, at, txt, rsn.Error(), rsn)
+}
+
+// pop expects txt[rp] to be the found closing brace.
+func (s *Scanner) pop(txt []byte, rp int64, found byte) (meta bool, err error) {
+	end := len(s.nest)
+	if end == 0 {
+		return false, s.scanError(ScanUnnested, s.posAt(txt, rp), txt,
+			fmt.Sprintf("poping '%c' from unnested", found),
+			nil)
+	}
+	end--
+	n := s.nest[end]
+	s.nest = s.nest[:end]
+	if end < s.metaAt {
+		s.metaAt = 0
+	}
+	if n.cbrace != found {
+		return false, s.scanError(ScanUnbalanced, s.posAt(txt, rp), txt,
+			fmt.Sprintf("unbalanced bracing: '%c', expected '%c'", found, n.cbrace),
+			nil)
+	}
+	return n.meta, nil
+}
+
+// callbackError wraps the error returned from a Handler into a ScanError at
+// the start of the current token.
+func (s *Scanner) callbackError(err error, txt []byte) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(*ScanError); ok {
+		return err
 	}
+	return s.scanError(ScanCallback, s.tokStart, txt, err.Error(), err)
 }
 
 // TODO make it fast & use it everywhere (implemented 1st for ppretty)
@@ -235,6 +323,13 @@ func (s *Scanner) skipspace(txt []byte) (res int) {
 	return res
 }
 
+func (s *Scanner) skipUAtom(txt []byte) int {
+	if s.UTF8 {
+		return skipUAtom8(txt)
+	}
+	return skipUAtom(txt)
+}
+
 func skipUAtom(txt []byte) (atom int) {
 	for atom < len(txt) {
 		if isAny(txt[atom], ccSpace|ccBegin|ccEnd|ccTok) {
@@ -245,15 +340,21 @@ func skipUAtom(txt []byte) (atom int) {
 	return -1
 }
 
-func skipQAtom(txt []byte, sb *bytes.Buffer) (atom int, ahead atomHeadMode) {
+// skipQAtom returns the length of the quoted atom in txt up to but not
+// including the closing quote or -1 if txt ends before the closing quote. If
+// the atom contained escapes the unescaped atom is written to sb and buffered
+// is true. With raw, escapes are skipped but not removed from the atom.
+func skipQAtom(txt []byte, sb *bytes.Buffer, raw bool) (atom int, ahead atomHeadMode, buffered bool) {
+	if raw {
+		return skipRawQAtom(txt)
+	}
 	sb.Reset()
 	for atom < len(txt) {
 		switch c := txt[atom]; c {
 		case '"':
-			return atom, aheadQuote
+			return atom, aheadQuote, false
 		case '\\':
-			sb.Reset()
-			sb.Write(txt[:atom]) // TODO error
+			sb.Write(txt[:atom])
 			esc := true
 			for atom++; atom < len(txt); atom++ {
 				if esc {
@@ -262,7 +363,7 @@ func skipQAtom(txt []byte, sb *bytes.Buffer) (atom int, ahead atomHeadMode) {
 				} else {
 					switch c := txt[atom]; c {
 					case '"':
-						return atom, aheadQuote
+						return atom, aheadQuote, true
 					case '\\':
 						esc = true
 					default:
@@ -271,172 +372,403 @@ func skipQAtom(txt []byte, sb *bytes.Buffer) (atom int, ahead atomHeadMode) {
 				}
 			}
 			if esc {
-				return -1, aheadEsc
+				return -1, aheadEsc, true
 			} else {
-				return -1, aheadQuote
+				return -1, aheadQuote, true
 			}
 		}
 		atom++
 	}
-	return -1, aheadQuote
+	return -1, aheadQuote, false
 }
 
-func (s *Scanner) callBegin(o, c byte) {
-	s.Begin(s.meta, o)
+func skipRawQAtom(txt []byte) (atom int, ahead atomHeadMode, buffered bool) {
+	for ; atom < len(txt); atom++ {
+		switch txt[atom] {
+		case '"':
+			return atom, aheadQuote, false
+		case '\\':
+			if atom++; atom == len(txt) {
+				return -1, aheadEsc, false
+			}
+		}
+	}
+	return -1, aheadQuote, false
+}
+
+// at returns the unresolved position of txt[rp] where txt is the current
+// chunk of input passed to Scan. Line and column of positions are computed
+// lazily by resolve.
+func (s *Scanner) at(rp int64) Position {
+	return Position{Offset: s.pos + rp}
+}
+
+// posAt returns the resolved position of txt[rp] where txt is the current
+// chunk of input passed to Scan. Subsequent calls must not decrease rp.
+func (s *Scanner) posAt(txt []byte, rp int64) Position {
+	s.cur.advance(txt[s.cur.Offset-s.pos : rp])
+	return s.cur
+}
+
+// resolve computes line and column of p if p is an unresolved position in
+// the current chunk of input.
+func (s *Scanner) resolve(txt []byte, p Position) Position {
+	if p.IsValid() || txt == nil {
+		return p
+	}
+	rp := p.Offset - s.pos
+	if rp < s.cur.Offset-s.pos || rp > int64(len(txt)) {
+		return p
+	}
+	return s.posAt(txt, rp)
+}
+
+// tokenStart returns the start of the token at txt[rp] which includes the
+// pending Meta prefix, if any.
+func (s *Scanner) tokenStart(txt []byte, rp int64) Position {
+	if s.meta {
+		return s.metaStart
+	}
+	return s.at(rp)
+}
+
+func (s *Scanner) callBegin(txt []byte, rp int64, o, c byte) (err error) {
+	s.tokStart = s.tokenStart(txt, rp)
+	s.tokEnd = s.at(rp + 1)
+	if s.MaxDepth > 0 || s.MaxMetaDepth > 0 {
+		if err = s.checkBegin(txt); err != nil {
+			return err
+		}
+	}
+	if s.Handler == nil {
+		s.Begin(s.meta, o)
+	} else if err = s.Handler.Begin(s.meta, o); err != nil {
+		return s.callbackError(err, txt)
+	}
 	s.push(s.meta, c)
 	s.meta = false
+	return nil
+}
+
+func (s *Scanner) callAtom(txt []byte, meta bool, atom []byte, quoted bool) error {
+	if !s.checkAtom {
+		s.Atom(meta, atom, quoted)
+		return nil
+	}
+	return s.handleAtom(txt, meta, atom, quoted)
+}
+
+// callQAtom reports a quoted atom. With Escapes, atom is still escaped and
+// is decoded before it is reported.
+func (s *Scanner) callQAtom(txt []byte, meta bool, atom []byte) error {
+	if s.Escapes && bytes.IndexByte(atom, '\\') >= 0 {
+		res, at, err := unescape(s.escBuf[:0], atom)
+		if err != nil {
+			return s.escapeError(txt, meta, atom, at, err)
+		}
+		s.escBuf = res
+		atom = res
+	}
+	return s.callAtom(txt, meta, atom, true)
+}
+
+func (s *Scanner) handleAtom(txt []byte, meta bool, atom []byte, quoted bool) error {
+	if s.MaxAtomLen > 0 && len(atom) > s.MaxAtomLen {
+		return s.limitError(txt, s.tokStart, "atom length", s.MaxAtomLen)
+	}
+	if s.Handler == nil {
+		s.Atom(meta, atom, quoted)
+		return nil
+	}
+	return s.callbackError(s.Handler.Atom(meta, atom, quoted), txt)
+}
+
+// aheadTooLong reports whether the incomplete atom in atomHead already
+// exceeds MaxAtomLen. With Escapes, quoted atoms are kept escaped until they
+// are complete. Then atomHead must be long enough to exceed MaxAtomLen even
+// if it only consists of the longest escape sequences.
+func (s *Scanner) aheadTooLong() bool {
+	max := s.MaxAtomLen
+	if s.Escapes && (s.aheadMode == aheadQuote || s.aheadMode == aheadEsc) {
+		max = (max+1)*maxEscapeLen - 1
+	}
+	return len(s.atomHead) > max
+}
+
+func (s *Scanner) callMetaAtom(txt []byte) error {
+	s.tokStart, s.tokEnd = s.metaStart, s.metaStart.next()
+	s.meta = false
+	return s.callAtom(txt, false, metaAtom, false)
 }
 
-func (s *Scanner) callEnd(c byte) {
+func (s *Scanner) callEnd(txt []byte, rp int64, c byte) (err error) {
 	if s.meta {
-		s.Atom(false, metaAtom, false)
-		s.meta = false
+		if err = s.callMetaAtom(txt); err != nil {
+			return err
+		}
 	}
-	m := s.pop(c)
-	s.End(m, c)
+	m, err := s.pop(txt, rp, c)
+	if err != nil {
+		return err
+	}
+	s.tokStart = s.at(rp)
+	s.tokEnd = s.at(rp + 1)
+	if s.Handler == nil {
+		s.End(m, c)
+		return nil
+	}
+	return s.callbackError(s.Handler.End(m, c), txt)
 }
 
 var metaAtom = []byte{Meta}
 
-func (s *Scanner) Scan(txt []byte) (err error) {
-	rp, end := int64(0), int64(len(txt))
-	defer func() {
-		s.pos += rp
-		if p := recover(); p != nil {
-			switch x := p.(type) {
-			case *ScanError:
-				err = x
-			case error:
-				err = &ScanError{hint: s.SrcHint, pos: s.pos, msg: x.Error(), rsn: x}
-			default:
-				err = &ScanError{
-					hint: s.SrcHint,
-					pos:  s.pos,
-					msg:  fmt.Sprintf("%T:[%v]", p, p),
-				}
-			}
+func (s *Scanner) Scan(txt []byte) error {
+	s.chunk = txt
+	s.checkAtom = s.Handler != nil || s.MaxAtomLen > 0
+	bad, badAt := int64(-1), s.u8Pos
+	if s.CheckUTF8 {
+		var prev bool
+		if bad, prev = s.checkUTF8(txt); !prev {
+			badAt = s.at(bad)
 		}
-	}()
+	}
+	var rp int64
+	var err error
+	if bad < 0 {
+		rp, err = s.scan(txt)
+	} else if rp, err = s.scan(txt[:bad]); err == nil {
+		// report the tokens before the invalid input first
+		err = s.scanError(ScanInvalidUTF8, badAt, txt, "invalid UTF-8 sequence", nil)
+	}
+	if s.atomHead != nil {
+		s.aheadPos = s.resolve(txt, s.aheadPos)
+		if err == nil && s.MaxAtomLen > 0 && s.aheadTooLong() {
+			err = s.limitError(txt, s.aheadPos, "atom length", s.MaxAtomLen)
+		}
+	}
+	if s.meta {
+		s.metaStart = s.resolve(txt, s.metaStart)
+	}
+	if s.cmt.mode != cmtNone {
+		s.cmt.start = s.resolve(txt, s.cmt.start)
+	}
+	if s.u8n > 0 {
+		s.u8Pos = s.resolve(txt, s.u8Pos)
+	}
+	if s.cur.Offset < s.pos+rp {
+		s.posAt(txt, rp)
+	}
+	s.pos += rp
+	s.chunk = nil
+	return err
+}
+
+// scan returns the number of bytes from txt that were consumed.
+func (s *Scanner) scan(txt []byte) (rp int64, err error) {
+	end := int64(len(txt))
+	if s.cmt.mode != cmtNone {
+		var done bool
+		if rp, done, err = s.comment(txt, 0); err != nil || !done {
+			return rp, err
+		}
+	}
 	if s.atomHead != nil {
 		if end == 0 {
-			return nil
+			return 0, nil
 		}
-		if s.aheadMode == aheadPlain {
-			aLen := skipUAtom(txt)
-			if aLen < 0 {
+		if s.aheadMode == aheadPlain && s.RawAtoms {
+			used, ok := s.rawStart(txt)
+			if !ok {
 				s.atomHead = append(s.atomHead, txt...)
-				rp = end
-				return nil
+				return end, nil
 			}
-			s.atomHead = append(s.atomHead, txt[:aLen]...)
-			s.Atom(s.meta, s.atomHead, false)
-			s.meta = false
-			s.atomHead = nil
-			rp = int64(aLen)
+			rp = int64(used)
+		}
+		if s.aheadMode == aheadPlain {
+			head, tail, ok := 0, 0, true
+			if s.UTF8 {
+				if head, tail, ok = s.spaceAhead(txt); !ok {
+					s.atomHead = append(s.atomHead, txt...)
+					return end, nil
+				}
+			}
+			if head > 0 {
+				rp = int64(tail)
+				err = s.callSplitAtom(txt, head)
+			} else {
+				aLen := s.skipUAtom(txt)
+				if aLen < 0 {
+					s.atomHead = append(s.atomHead, txt...)
+					return end, nil
+				}
+				s.atomHead = append(s.atomHead, txt[:aLen]...)
+				rp = int64(aLen)
+				s.tokStart, s.tokEnd = s.aheadPos, s.at(rp)
+				err = s.callAtom(txt, s.meta, s.atomHead, false)
+			}
+		} else if s.aheadMode == aheadRaw {
+			used, done := s.rawScan(txt[rp:])
+			if rp += int64(used); !done {
+				return end, nil
+			}
+			s.tokStart, s.tokEnd = s.aheadPos, s.at(rp)
+			err = s.callRawAtom(txt, s.meta, s.atomHead)
 		} else {
 			if s.aheadMode == aheadEsc {
 				s.atomHead = append(s.atomHead, txt[rp])
 				rp++
 			}
-			aLen, aEsc := skipQAtom(txt[rp:], &s.qatomBuf)
+			aLen, aEsc, buffered := skipQAtom(txt[rp:], &s.qatomBuf, s.Escapes)
 			if aLen < 0 {
-				if s.qatomBuf.Len() == 0 {
-					s.atomHead = append(s.atomHead, txt...)
+				if !buffered {
+					s.atomHead = append(s.atomHead, txt[rp:]...)
 				} else {
 					s.atomHead = append(s.atomHead, s.qatomBuf.Bytes()...)
 				}
 				s.aheadMode = aEsc
-				return nil
+				return end, nil
 			}
-			if s.qatomBuf.Len() == 0 {
+			if !buffered {
 				s.atomHead = append(s.atomHead, txt[rp:rp+int64(aLen)]...)
 			} else {
 				s.atomHead = append(s.atomHead, s.qatomBuf.Bytes()...)
 			}
-			s.Atom(s.meta, s.atomHead, true)
-			s.meta = false
-			s.atomHead = nil
 			rp += int64(aLen + 1)
+			s.tokStart, s.tokEnd = s.aheadPos, s.at(rp)
+			err = s.callQAtom(txt, s.meta, s.atomHead)
+		}
+		s.meta = false
+		s.atomHead = nil
+		if err != nil {
+			return rp, err
 		}
 	}
 	// assert s.atomHead == nil
 	for rp < end {
-		if wse := s.skipspace(txt[rp:]); wse > 0 {
+		var wse int
+		if s.UTF8 {
+			wse = s.skipspace8(txt[rp:])
+		} else {
+			wse = s.skipspace(txt[rp:])
+		}
+		if wse > 0 {
 			if s.meta {
-				s.Atom(false, metaAtom, false)
-				s.meta = false
+				if err = s.callMetaAtom(txt); err != nil {
+					return rp, err
+				}
 			}
 			rp += int64(wse)
 			if rp >= end {
-				return nil
+				return rp, nil
 			}
 		}
+		if txt[rp] == CommentStart && s.Comments {
+			if s.meta {
+				if err = s.callMetaAtom(txt); err != nil {
+					return rp, err
+				}
+			}
+			var done bool
+			if rp, done, err = s.startComment(txt, rp); err != nil || !done {
+				return rp, err
+			}
+			continue
+		}
 		switch txt[rp] {
 		case '(':
-			s.callBegin('(', ')')
-			rp++
+			err = s.callBegin(txt, rp, '(', ')')
 		case '[':
-			s.callBegin('[', ']')
-			rp++
+			err = s.callBegin(txt, rp, '[', ']')
 		case '{':
-			s.callBegin('{', '}')
-			rp++
+			err = s.callBegin(txt, rp, '{', '}')
 		case ')':
-			s.callEnd(')')
-			rp++
+			err = s.callEnd(txt, rp, ')')
 		case ']':
-			s.callEnd(']')
-			rp++
+			err = s.callEnd(txt, rp, ']')
 		case '}':
-			s.callEnd('}')
-			rp++
+			err = s.callEnd(txt, rp, '}')
 		case '"':
+			start := s.tokenStart(txt, rp)
 			rp++
-			aLen, aEsc := skipQAtom(txt[rp:], &s.qatomBuf)
+			aLen, aEsc, buffered := skipQAtom(txt[rp:], &s.qatomBuf, s.Escapes)
 			if aLen < 0 {
-				if len := s.qatomBuf.Len(); len == 0 {
+				if !buffered {
 					s.atomHead = make([]byte, end-rp)
 					copy(s.atomHead, txt[rp:])
 				} else {
-					s.atomHead = make([]byte, len)
+					s.atomHead = make([]byte, s.qatomBuf.Len())
 					copy(s.atomHead, s.qatomBuf.Bytes())
 				}
 				s.aheadMode = aEsc
-				rp = end
+				s.aheadPos = start
+				return end, nil
+			}
+			ae := rp + int64(aLen)
+			s.tokStart, s.tokEnd = start, s.at(ae+1)
+			if !buffered {
+				err = s.callQAtom(txt, s.meta, txt[rp:ae])
 			} else {
-				if s.qatomBuf.Len() == 0 {
-					ae := rp + int64(aLen)
-					s.Atom(s.meta, txt[rp:ae], true)
-				} else {
-					s.Atom(s.meta, s.qatomBuf.Bytes(), true)
-				}
-				s.meta = false
-				rp += int64(aLen + 1)
+				err = s.callQAtom(txt, s.meta, s.qatomBuf.Bytes())
 			}
+			s.meta = false
+			rp = ae
 		case Meta:
 			if s.meta {
-				s.Atom(true, metaAtom, false)
+				s.tokStart, s.tokEnd = s.metaStart, s.at(rp+1)
 				s.meta = false
+				err = s.callAtom(txt, true, metaAtom, false)
 			} else {
+				s.metaStart = s.at(rp)
 				s.meta = true
 			}
-			rp++
 		default:
-			aLen := skipUAtom(txt[rp:])
+			start := s.tokenStart(txt, rp)
+			if s.RawAtoms && txt[rp] == RawStart {
+				if n := int64(countRaw(txt[rp:])); rp+n < end && txt[rp+n] == '"' {
+					body := rp + n + 1
+					if e := findRawEnd(txt[body:], int(n)); e >= 0 {
+						ae := body + int64(e)
+						s.tokStart, s.tokEnd = start, s.at(ae+1+n)
+						err = s.callRawAtom(txt, s.meta, txt[body:ae])
+						s.meta = false
+						rp = ae + 1 + n
+						if err != nil {
+							return rp, err
+						}
+						continue
+					}
+					s.atomHead = make([]byte, 0, end-body)
+					s.aheadMode = aheadRaw
+					s.aheadPos = start
+					s.rawN, s.rawClose = int(n), 0
+					s.rawScan(txt[body:])
+					return end, nil
+				}
+			}
+			aLen := s.skipUAtom(txt[rp:])
 			if aLen < 0 {
 				s.atomHead = make([]byte, end-rp)
 				copy(s.atomHead, txt[rp:])
 				s.aheadMode = aheadPlain
-				rp = end
-			} else {
-				ae := rp + int64(aLen)
-				s.Atom(s.meta, txt[rp:ae], false)
-				s.meta = false
-				rp = ae
+				s.aheadPos = start
+				return end, nil
 			}
+			ae := rp + int64(aLen)
+			s.tokStart, s.tokEnd = start, s.at(ae)
+			err = s.callAtom(txt, s.meta, txt[rp:ae], false)
+			s.meta = false
+			if err != nil {
+				return rp, err
+			}
+			rp = ae
+			continue
+		}
+		if err != nil {
+			return rp, err
 		}
+		rp++
 	}
-	return err
+	return rp, nil
 }
 
 func (s *Scanner) ScanString(str string) (err error) {
@@ -454,17 +786,23 @@ var buf4k = sync.Pool{
 	},
 }
 
+// Read scans all input from rd and finishes scanning at the end of input.
+// Errors from rd are reported as ScanError of kind ScanFailed.
 func (s *Scanner) Read(rd io.Reader) (err error) {
 	buf := buf4k.Get().([]byte)
 	defer func() { buf4k.Put(buf) }()
-	for sz, err := rd.Read(buf); err == nil; sz, err = rd.Read(buf) {
-		serr := s.Scan(buf[:sz])
-		if serr != nil {
-			return serr
+	for {
+		sz, err := rd.Read(buf)
+		if sz > 0 {
+			if serr := s.Scan(buf[:sz]); serr != nil {
+				return serr
+			}
+		}
+		switch {
+		case err == io.EOF:
+			return s.Finish()
+		case err != nil:
+			return s.scanError(ScanFailed, s.cur, nil, err.Error(), err)
 		}
 	}
-	if err == io.EOF {
-		err = s.Finish()
-	}
-	return err
 }
@@ -2,6 +2,7 @@ package xsx
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -190,7 +191,7 @@ func TestUnbalanced(t *testing.T) {
 		if scnErr.Message() != "unbalanced bracing: '}', expected ')'" {
 			t.Error(scnErr.Message())
 		}
-		if scnErr.Error() != "@1:unbalanced bracing: '}', expected ')'" {
+		if scnErr.Error() != "1:2: unbalanced bracing: '}', expected ')'\n(}\n ^" {
 			t.Error(scnErr.Error())
 		}
 	}
@@ -220,23 +221,42 @@ func TestPrematureEndOfString(t *testing.T) {
 	}
 }
 
-func beginFail(isMeta bool, brace byte) {
-	panic(fmt.Errorf("begin fails with meta=%t brace=%c", isMeta, brace))
+type beginFail struct{}
+
+func (beginFail) Begin(isMeta bool, brace byte) error {
+	return fmt.Errorf("begin fails with meta=%t brace=%c", isMeta, brace)
 }
 
+func (beginFail) End(isMeta bool, brace byte) error { return nil }
+
+func (beginFail) Atom(isMeta bool, atom []byte, quoted bool) error { return nil }
+
 func TestScanFailOnBegin(t *testing.T) {
 	for _, xsx := range []string{"(", "[", "{"} {
-		s := NewScanner(beginFail, EndNop, AtomNop)
+		s := NewHandlerScanner(beginFail{})
 		err := s.ScanString(xsx)
 		if err == nil {
 			t.Error("expected error, got none")
 		}
-		if err.Error() != "@0:begin fails with meta=false brace="+xsx {
+		if err.Error() != "1:1: begin fails with meta=false brace="+xsx+"\n"+xsx+"\n^" {
 			t.Errorf("unexpected error message: '%s'", err.Error())
 		}
 	}
 }
 
+func TestScanPanicPropagates(t *testing.T) {
+	s := NewScanner(BeginNop, EndNop, func(bool, []byte, bool) {
+		panic("callback bug")
+	})
+	defer func() {
+		if p := recover(); p != "callback bug" {
+			t.Errorf("unexpected panic: %v", p)
+		}
+	}()
+	s.ScanString("(foo)")
+	t.Error("callback panic was not propagated")
+}
+
 func ExampleScanEndFromNoToken() {
 	for _, xsx := range []string{"( )", "[ ]", "{ }"} {
 		s := NewTestScanner(true)
@@ -509,3 +529,23 @@ func ExampleScanner_EscIn2ndSplit() {
 	// Output:
 	// atom: true [foo e\scape] true
 }
+
+func TestScanner_2SqatomEscAtSplit(t *testing.T) {
+	twoStepScan(t, `"\"b"`)
+	twoStepScan(t, `"a\\"`)
+	twoStepScan(t, `("\\" "\"")`)
+}
+
+func TestScanner_FinishPendingAtom(t *testing.T) {
+	var out bytes.Buffer
+	s := NewScanner(exampleBegin(&out), exampleEnd(&out), exampleAtom(&out))
+	if err := s.Scan([]byte("(foo")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Finish(); !errors.Is(err, ScanTruncated) {
+		t.Errorf("expected truncated input, got %v", err)
+	}
+	if out.String() != "begin: false (\natom: false [foo] false\n" {
+		t.Errorf("unexpected events:\n%s", out.String())
+	}
+}
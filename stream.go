@@ -0,0 +1,135 @@
+package xsx
+
+import (
+	"io"
+	"strconv"
+	"strings"
+)
+
+// An Encoder writes a stream of XSX values to an output. Each value is
+// terminated by a newline.
+type Encoder struct {
+	wr         lastByteWriter
+	newPrinter func(io.Writer) Printer
+}
+
+// NewEncoder returns an Encoder that writes to wr using the CompactPrinter.
+func NewEncoder(wr io.Writer) *Encoder {
+	return &Encoder{
+		wr:         lastByteWriter{wr: wr},
+		newPrinter: func(w io.Writer) Printer { return Compact(w) },
+	}
+}
+
+// SetPrinter makes the Encoder use the Printer returned by newPrinter to
+// write values, e.g.
+//
+//	enc.SetPrinter(func(w io.Writer) xsx.Printer { return xsx.Pretty(w, "  ") })
+//
+// The Encoder calls newPrinter once for each value.
+func (enc *Encoder) SetPrinter(newPrinter func(io.Writer) Printer) {
+	enc.newPrinter = newPrinter
+}
+
+// Encode writes the XSX encoding of v followed by a newline. See Marshal for
+// how Go values are mapped to XSX.
+func (enc *Encoder) Encode(v any) error {
+	if err := marshal(enc.newPrinter(&enc.wr), v); err != nil {
+		return err
+	}
+	if enc.wr.last != '\n' {
+		if _, err := enc.wr.Write([]byte{'\n'}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lastByteWriter remembers the last byte written.
+type lastByteWriter struct {
+	wr   io.Writer
+	last byte
+}
+
+func (w *lastByteWriter) Write(p []byte) (n int, err error) {
+	n, err = w.wr.Write(p)
+	if n > 0 {
+		w.last = p[n-1]
+	}
+	return n, err
+}
+
+// A Decoder reads a stream of XSX values from an input.
+type Decoder struct {
+	d decodeState
+}
+
+// NewDecoder returns a Decoder that reads from rd. The Decoder reads rd in
+// blocks and may read data beyond the values requested.
+func NewDecoder(rd io.Reader) *Decoder {
+	return &Decoder{d: decodeState{p: NewPullParser(rd)}}
+}
+
+// DisallowUnknownFields makes Decode fail when an input struct has a key that
+// does not match any field of the Go struct.
+func (dec *Decoder) DisallowUnknownFields() { dec.d.disallowUnknown = true }
+
+// UseNumber makes Decode store atoms that are not quoted and that are valid
+// numbers as Number instead of string when decoding into an interface.
+func (dec *Decoder) UseNumber() { dec.d.useNumber = true }
+
+// Decode reads the next XSX value from the input and stores it in the value
+// pointed to by v. At the end of input Decode returns io.EOF. See Unmarshal
+// for how XSX is mapped to Go values.
+func (dec *Decoder) Decode(v any) error {
+	if err := dec.d.unmarshal(v); err != PullEOI {
+		return err
+	}
+	return io.EOF
+}
+
+// More reports whether there is another value in the current sequence or, at
+// the top level, in the input.
+func (dec *Decoder) More() bool {
+	t, err := dec.d.p.Peek()
+	return err == nil && t.Token != TokEOI && t.Token != TokEnd
+}
+
+// Token returns the next token from the input. At the end of input Token
+// returns io.EOF. Token can be mixed with calls to Decode, e.g. to decode the
+// elements of a top-level sequence one by one.
+func (dec *Decoder) Token() (TokenInfo, error) {
+	p := dec.d.p
+	switch tok, err := p.Next(); {
+	case err != nil:
+		return TokenInfo{Token: tok}, err
+	case tok == TokEOI:
+		return TokenInfo{Token: tok}, io.EOF
+	}
+	return p.tokenInfo(&p.toks[p.tokRd-1]), nil
+}
+
+// Number is an atom that is a number. It is used by Decoder.UseNumber.
+type Number string
+
+func (n Number) String() string { return string(n) }
+
+// Float64 returns the number as float64.
+func (n Number) Float64() (float64, error) {
+	return strconv.ParseFloat(string(n), 64)
+}
+
+// Int64 returns the number as int64.
+func (n Number) Int64() (int64, error) {
+	return strconv.ParseInt(string(n), 10, 64)
+}
+
+// isNumber reports whether atom is a decimal number. Note that
+// strconv.ParseFloat also accepts e.g. "inf", "NaN" and hex numbers.
+func isNumber(atom string) bool {
+	if strings.Trim(atom, "+-.0123456789eE") != "" {
+		return false
+	}
+	_, err := strconv.ParseFloat(atom, 64)
+	return err == nil
+}
@@ -0,0 +1,119 @@
+package xsx
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stvp/assert"
+)
+
+type testLogEntry struct {
+	Level string `xsx:"level"`
+	Msg   string `xsx:"msg"`
+	Count int    `xsx:"count,omitempty"`
+}
+
+func ExampleEncoder() {
+	enc := NewEncoder(os.Stdout)
+	mustExample(enc.Encode(testLogEntry{Level: "info", Msg: "started"}))
+	mustExample(enc.Encode(testLogEntry{Level: "warn", Msg: "retry", Count: 3}))
+	enc.SetPrinter(func(w io.Writer) Printer { return Pretty(w, "  ") })
+	mustExample(enc.Encode([]string{"a", "b"}))
+	// Output:
+	// {level info msg started}
+	// {level warn msg retry count 3}
+	// [
+	//   a
+	//   b
+	// ]
+}
+
+func ExampleDecoder() {
+	dec := NewDecoder(strings.NewReader(`
+{level info msg started}
+{level warn msg retry count 3}`))
+	for {
+		var e testLogEntry
+		err := dec.Decode(&e)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			fmt.Println(err)
+			return
+		}
+		fmt.Printf("%+v\n", e)
+	}
+	// Output:
+	// {Level:info Msg:started Count:0}
+	// {Level:warn Msg:retry Count:3}
+}
+
+func ExampleDecoder_Token() {
+	dec := NewDecoder(strings.NewReader(`[{msg a} {msg b}]`))
+	tok, err := dec.Token()
+	mustExample(err)
+	fmt.Printf("%s %c\n", tok.Token, tok.Brace)
+	for dec.More() {
+		var e testLogEntry
+		mustExample(dec.Decode(&e))
+		fmt.Println(e.Msg)
+	}
+	tok, err = dec.Token()
+	mustExample(err)
+	fmt.Printf("%s %c\n", tok.Token, tok.Brace)
+	_, err = dec.Token()
+	fmt.Println(err)
+	// Output:
+	// begin [
+	// a
+	// b
+	// end ]
+	// EOF
+}
+
+func TestEncoder_roundTrip(t *testing.T) {
+	var sb strings.Builder
+	enc := NewEncoder(&sb)
+	enc.SetPrinter(func(w io.Writer) Printer { return Indenting(w, "\t") })
+	in := []testLogEntry{{"a", "x y", 1}, {"b", "", 0}, {"c", "(", 2}}
+	for _, e := range in {
+		assert.Nil(t, enc.Encode(e))
+	}
+	dec := NewDecoder(strings.NewReader(sb.String()))
+	var out []testLogEntry
+	for dec.More() {
+		var e testLogEntry
+		assert.Nil(t, dec.Decode(&e))
+		out = append(out, e)
+	}
+	assert.Equal(t, in, out)
+	assert.Equal(t, io.EOF, dec.Decode(&testLogEntry{}))
+}
+
+func TestDecoder_DisallowUnknownFields(t *testing.T) {
+	dec := NewDecoder(strings.NewReader(`{level info foo bar}`))
+	var e testLogEntry
+	assert.Nil(t, dec.Decode(&e))
+	dec = NewDecoder(strings.NewReader(`{level info foo bar}`))
+	dec.DisallowUnknownFields()
+	err := dec.Decode(&e)
+	assert.True(t, err != nil && strings.Contains(err.Error(), "unknown field 'foo'"), err)
+}
+
+func TestDecoder_UseNumber(t *testing.T) {
+	dec := NewDecoder(strings.NewReader(`[1 -2.5e3 "3" -inf x] [1]`))
+	dec.UseNumber()
+	var x any
+	assert.Nil(t, dec.Decode(&x))
+	assert.Equal(t, []any{Number("1"), Number("-2.5e3"), "3", "-inf", "x"}, x)
+	n, err := x.([]any)[1].(Number).Float64()
+	assert.Nil(t, err)
+	assert.Equal(t, -2500.0, n)
+	dec = NewDecoder(strings.NewReader(`[1`))
+	err = dec.Decode(&x)
+	assert.True(t, errors.Is(err, ScanTruncated), err)
+}
@@ -16,7 +16,14 @@ type Column struct {
 
 type Definition []Column
 
+// ReadDef reads a table definition from xrd. If xrd.Intern is nil, ReadDef
+// sets it to a new xsx.Interner. Then column names and the repeated cell
+// values that are read with NextRow share their strings instead of
+// allocating a string for each atom.
 func ReadDef(xrd *xsx.PullParser) (res Definition, err error) {
+	if xrd.Intern == nil {
+		xrd.Intern = xsx.NewInterner(0).Intern
+	}
 	if err = xrd.NextBegin("[", xsx.NoMeta); err != nil {
 		return nil, err
 	}
@@ -82,23 +89,32 @@ func (tdef Definition) ColIndex(colName string) int {
 	return -1
 }
 
+// NextRow reads the next row of the table into row, which is reused if it is
+// big enough. Meta expressions between rows are treated as annotations and
+// are skipped.
 func (tdef Definition) NextRow(xrd *xsx.PullParser, row []gem.Expr) ([]gem.Expr, error) {
 	if row == nil || len(row) < len(tdef) || 3*len(row) < cap(row) {
 		row = make([]gem.Expr, len(tdef))
 	}
 	for {
-		if err := xrd.NextBegin("(", xsx.AllowMeta); err == xsx.PullEOI {
-			return nil, xsx.PullEOI
-		} else if err != nil {
+		next, err := xrd.Peek()
+		if err != nil {
 			return nil, err
 		}
-		if xrd.WasMeta() {
-			if err := xrd.SkipMeta(); err != nil {
-				return nil, err
-			}
-		} else {
+		if !next.Meta {
 			break
 		}
+		if _, err = xrd.Next(); err != nil {
+			return nil, err
+		}
+		if err = xrd.SkipMeta(); err != nil {
+			return nil, err
+		}
+	}
+	if err := xrd.NextBegin("(", xsx.NoMeta); err == xsx.PullEOI {
+		return nil, xsx.PullEOI
+	} else if err != nil {
+		return nil, err
 	}
 	for i := 0; i < len(tdef); i++ {
 		elem, err := gem.ReadNext(xrd)
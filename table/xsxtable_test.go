@@ -6,6 +6,7 @@ import (
 	"os"
 	"reflect"
 	"testing"
+	"unsafe"
 
 	"git.fractalqb.de/fractalqb/xsx"
 	"git.fractalqb.de/fractalqb/xsx/gem"
@@ -182,6 +183,28 @@ func TestNextRow(t *testing.T) {
 	}
 }
 
+func TestNextRow_intern(t *testing.T) {
+	input := pullStr(`[level msg] (info a) (info b) (level c)`)
+	tdef, err := ReadDef(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cells []string
+	var row []gem.Expr
+	for range 3 {
+		if row, err = tdef.NextRow(input, row); err != nil {
+			t.Fatal(err)
+		}
+		cells = append(cells, row[0].(*gem.Atom).Str)
+	}
+	if unsafe.StringData(cells[0]) != unsafe.StringData(cells[1]) {
+		t.Error("repeated cell values do not share memory")
+	}
+	if unsafe.StringData(cells[2]) != unsafe.StringData(tdef[0].Name) {
+		t.Error("cell value does not share memory with column name")
+	}
+}
+
 func TestSkipMetaRow(t *testing.T) {
 	input := pullStr(`[(foo int) bar (baz bool)]
 	(1 "word 1" false)
@@ -214,3 +237,25 @@ func TestReadUnicode(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, "äöüß", (row[1]).(*gem.Atom).Str)
 }
+
+func TestSkipMetaAtomRow(t *testing.T) {
+	input := pullStr(`[(foo int) bar]
+	\note (1 "word 1")
+	\[more notes] \\ (2 "word 2")`)
+	tdef, err := ReadDef(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	row, err := tdef.NextRow(input, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "1", row[0].(*gem.Atom).Str)
+	row, err = tdef.NextRow(input, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "2", row[0].(*gem.Atom).Str)
+	_, err = tdef.NextRow(input, nil)
+	assert.Equal(t, xsx.PullEOI, err)
+}
@@ -0,0 +1,481 @@
+package xsx
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Unmarshal parses the XSX data and stores the result in the value pointed
+// to by v. Unmarshal uses the inverse of the mapping used by Marshal. Into
+// an empty interface value Unmarshal stores atoms as string, sequences in
+// curly braces as map[string]any and all other sequences as []any, except for
+// '()' that is stored as nil. Meta flags of atoms and sequences are ignored.
+// Values that implement Unmarshaler read themselves with UnmarshalXSX.
+// Otherwise values that implement encoding.TextUnmarshaler are read from
+// atoms with UnmarshalText.
+//
+// Unmarshal expects exactly one top-level expression in data.
+func Unmarshal(data []byte, v any) error {
+	d := decodeState{p: NewPullParser(bytes.NewReader(data))}
+	if err := d.unmarshal(v); err != nil {
+		return err
+	}
+	switch tok, err := d.p.Next(); {
+	case err != nil:
+		return err
+	case tok != TokEOI:
+		return errors.New("xsx unmarshal: extra data after top-level value")
+	}
+	return nil
+}
+
+// InvalidUnmarshalError is returned when Unmarshal is not passed a non-nil
+// pointer.
+type InvalidUnmarshalError struct {
+	Type reflect.Type
+}
+
+func (err *InvalidUnmarshalError) Error() string {
+	if err.Type == nil {
+		return "xsx unmarshal: nil"
+	}
+	return "xsx unmarshal: non-pointer or nil " + err.Type.String()
+}
+
+// UnmarshalTypeError describes XSX input that cannot be stored into a Go
+// value of a specific type.
+type UnmarshalTypeError struct {
+	// Value describes the XSX input, e.g. 'atom "foo"' or 'sequence'
+	Value string
+	Type  reflect.Type
+	// Path is the path of the sequences that enclose the input in the XSX
+	// document. The Index of its last element is the index of the input.
+	Path Path
+	// Reason is the error that occurred while converting an atom, if any
+	Reason error
+}
+
+func (err *UnmarshalTypeError) Error() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "xsx unmarshal: cannot unmarshal %s into Go value of type %s",
+		err.Value,
+		err.Type)
+	if len(err.Path) > 0 {
+		fmt.Fprintf(&sb, " in %s element %d", err.Path, err.Path[len(err.Path)-1].Index)
+	}
+	return sb.String()
+}
+
+func (err *UnmarshalTypeError) Unwrap() error { return err.Reason }
+
+type decodeState struct {
+	p               *PullParser
+	disallowUnknown bool
+	useNumber       bool
+}
+
+func (d *decodeState) typeError(t reflect.Type, rsn error) error {
+	res := &UnmarshalTypeError{Type: t, Path: d.p.Path(), Reason: rsn}
+	if d.p.LastToken() == TokAtom {
+		res.Value = "atom " + strconv.Quote(d.p.Atom)
+	} else {
+		// the failing sequence itself is the last element of the path
+		res.Value = "sequence"
+		res.Path = res.Path[:len(res.Path)-1]
+	}
+	return res
+}
+
+// unmarshal reads the next expression from the PullParser into v.
+func (d *decodeState) unmarshal(v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return &InvalidUnmarshalError{Type: reflect.TypeOf(v)}
+	}
+	switch tok, err := d.p.Next(); {
+	case err != nil:
+		return err
+	case tok == TokEOI:
+		return PullEOI
+	case tok == TokEnd:
+		return fmt.Errorf("xsx unmarshal: unexpected end token '%c'", d.p.LastBrace())
+	}
+	return d.current(rv.Elem())
+}
+
+// next pulls the next token within a sequence. It reports the end of the
+// sequence with end == true.
+func (d *decodeState) next() (end bool, err error) {
+	switch tok, err := d.p.Next(); {
+	case tok == TokEOI:
+		if err != nil {
+			return false, err
+		}
+		return false, PullTruncated
+	case err != nil:
+		return false, err
+	case tok == TokEnd:
+		return true, nil
+	}
+	return false, nil
+}
+
+// current decodes the expression that starts with the last token into v.
+func (d *decodeState) current(v reflect.Value) error {
+	if d.p.LastToken() == TokBegin && d.p.LastBrace() == '(' {
+		switch v.Kind() {
+		case reflect.Pointer, reflect.Interface, reflect.Map, reflect.Slice:
+			if t, err := d.p.Peek(); err != nil {
+				return err
+			} else if t.Token == TokEnd {
+				if _, err = d.p.Next(); err != nil {
+					return err
+				}
+				v.SetZero()
+				return nil
+			}
+		}
+	}
+	if v.Kind() != reflect.Pointer && v.CanAddr() {
+		switch pt := reflect.PointerTo(v.Type()); {
+		case pt.Implements(unmarshalerType):
+			return v.Addr().Interface().(Unmarshaler).UnmarshalXSX(d.p)
+		case pt.Implements(textUnmarshalerType):
+			if d.p.LastToken() != TokAtom {
+				return d.typeError(v.Type(), nil)
+			}
+			tu := v.Addr().Interface().(encoding.TextUnmarshaler)
+			if err := tu.UnmarshalText(d.p.AtomBytes()); err != nil {
+				return d.typeError(v.Type(), err)
+			}
+			return nil
+		}
+	}
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return d.current(v.Elem())
+	case reflect.Interface:
+		if v.NumMethod() == 0 && (v.IsNil() || v.Elem().Kind() != reflect.Pointer) {
+			x, err := d.any()
+			if err != nil {
+				return err
+			}
+			if x == nil {
+				v.SetZero()
+			} else {
+				v.Set(reflect.ValueOf(x))
+			}
+			return nil
+		}
+		if v.IsNil() {
+			return d.typeError(v.Type(), nil)
+		}
+		return d.current(v.Elem())
+	}
+	if d.p.LastToken() == TokAtom {
+		return d.atom(v)
+	}
+	switch v.Kind() {
+	case reflect.Slice:
+		return d.slice(v)
+	case reflect.Array:
+		return d.array(v)
+	case reflect.Map:
+		return d.mapping(v)
+	case reflect.Struct:
+		return d.structure(v)
+	}
+	return d.typeError(v.Type(), nil)
+}
+
+func (d *decodeState) atom(v reflect.Value) error {
+	atom := d.p.Atom
+	if v.Type() == durationType {
+		dur, err := time.ParseDuration(atom)
+		if err != nil {
+			return d.typeError(v.Type(), err)
+		}
+		v.SetInt(int64(dur))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(atom)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(atom)
+		if err != nil {
+			return d.typeError(v.Type(), err)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(atom, 10, v.Type().Bits())
+		if err != nil {
+			return d.typeError(v.Type(), err)
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64, reflect.Uintptr:
+		u, err := strconv.ParseUint(atom, 10, v.Type().Bits())
+		if err != nil {
+			return d.typeError(v.Type(), err)
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(atom, v.Type().Bits())
+		if err != nil {
+			return d.typeError(v.Type(), err)
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.Uint8 {
+			return d.typeError(v.Type(), nil)
+		}
+		b, err := base64.StdEncoding.DecodeString(atom)
+		if err != nil {
+			return d.typeError(v.Type(), err)
+		}
+		v.SetBytes(b)
+	default:
+		return d.typeError(v.Type(), nil)
+	}
+	return nil
+}
+
+func (d *decodeState) slice(v reflect.Value) error {
+	if v.IsNil() {
+		v.Set(reflect.MakeSlice(v.Type(), 0, 0))
+	} else {
+		v.SetLen(0)
+	}
+	for i := 0; ; i++ {
+		if end, err := d.next(); err != nil {
+			return err
+		} else if end {
+			return nil
+		}
+		if i == v.Cap() {
+			v.Grow(1)
+		}
+		v.SetLen(i + 1)
+		elem := v.Index(i)
+		elem.SetZero()
+		if err := d.current(elem); err != nil {
+			return err
+		}
+	}
+}
+
+func (d *decodeState) array(v reflect.Value) error {
+	for i := 0; ; i++ {
+		if end, err := d.next(); err != nil {
+			return err
+		} else if end {
+			for ; i < v.Len(); i++ {
+				v.Index(i).SetZero()
+			}
+			return nil
+		}
+		if i >= v.Len() {
+			return fmt.Errorf("xsx unmarshal: too many elements for %s in %s",
+				v.Type(),
+				d.p.Path())
+		}
+		if err := d.current(v.Index(i)); err != nil {
+			return err
+		}
+	}
+}
+
+// key pulls the next key of a map or struct. It reports the end of the
+// sequence with end == true.
+func (d *decodeState) key() (end bool, err error) {
+	if end, err = d.next(); err != nil || end {
+		return end, err
+	}
+	if d.p.LastToken() != TokAtom {
+		return false, fmt.Errorf("xsx unmarshal: expected key atom in %s", d.p.Path())
+	}
+	return false, nil
+}
+
+// value pulls the first token of the value that belongs to key.
+func (d *decodeState) value(key string) error {
+	if end, err := d.next(); err != nil {
+		return err
+	} else if end {
+		return fmt.Errorf("xsx unmarshal: missing value for key '%s'", key)
+	}
+	return nil
+}
+
+func (d *decodeState) mapping(v reflect.Value) error {
+	t := v.Type()
+	if v.IsNil() {
+		v.Set(reflect.MakeMap(t))
+	}
+	for {
+		if end, err := d.key(); err != nil {
+			return err
+		} else if end {
+			return nil
+		}
+		key := reflect.New(t.Key()).Elem()
+		switch kk := key.Kind(); {
+		case reflect.PointerTo(t.Key()).Implements(textUnmarshalerType):
+			tu := key.Addr().Interface().(encoding.TextUnmarshaler)
+			if err := tu.UnmarshalText(d.p.AtomBytes()); err != nil {
+				return d.typeError(t.Key(), err)
+			}
+		case kk == reflect.String || kk == reflect.Bool ||
+			reflect.Int <= kk && kk <= reflect.Uintptr:
+			if err := d.atom(key); err != nil {
+				return err
+			}
+		default:
+			return d.typeError(t.Key(), nil)
+		}
+		keyStr := d.p.Atom
+		if err := d.value(keyStr); err != nil {
+			return err
+		}
+		elem := reflect.New(t.Elem()).Elem()
+		if err := d.current(elem); err != nil {
+			return err
+		}
+		v.SetMapIndex(key, elem)
+	}
+}
+
+func (d *decodeState) structure(v reflect.Value) error {
+	fields, err := structFields(v.Type())
+	if err != nil {
+		return err
+	}
+	for {
+		if end, err := d.key(); err != nil {
+			return err
+		} else if end {
+			return nil
+		}
+		name := d.p.Atom
+		if err := d.value(name); err != nil {
+			return err
+		}
+		f := findField(fields, name)
+		if f == nil {
+			if d.disallowUnknown {
+				return fmt.Errorf("xsx unmarshal: unknown field '%s' for %s in %s",
+					name,
+					v.Type(),
+					d.p.Path())
+			}
+			if err := d.p.SkipCurrent(); err != nil {
+				return err
+			}
+			continue
+		}
+		fv, err := fieldByIndexAlloc(v, f.index)
+		if err != nil {
+			return err
+		}
+		if err := d.current(fv); err != nil {
+			return err
+		}
+	}
+}
+
+func findField(fields []field, name string) *field {
+	for i := range fields {
+		if fields[i].name == name {
+			return &fields[i]
+		}
+	}
+	for i := range fields {
+		if strings.EqualFold(fields[i].name, name) {
+			return &fields[i]
+		}
+	}
+	return nil
+}
+
+// fieldByIndexAlloc is like reflect.Value.FieldByIndex but allocates nil
+// pointers to embedded structs. It fails if such a pointer is nil and cannot
+// be set because the embedded struct type is unexported.
+func fieldByIndexAlloc(v reflect.Value, index []int) (reflect.Value, error) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				if !v.CanSet() {
+					return v, fmt.Errorf(
+						"xsx unmarshal: cannot set embedded pointer to unexported struct %s",
+						v.Type().Elem())
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, nil
+}
+
+// any reads the current expression into a generic Go value.
+func (d *decodeState) any() (any, error) {
+	if d.p.LastToken() == TokAtom {
+		if d.useNumber && !d.p.WasQuot && isNumber(d.p.Atom) {
+			return Number(d.p.Atom), nil
+		}
+		return d.p.Atom, nil
+	}
+	switch d.p.LastBrace() {
+	case '{':
+		res := make(map[string]any)
+		for {
+			if end, err := d.key(); err != nil {
+				return res, err
+			} else if end {
+				return res, nil
+			}
+			key := d.p.Atom
+			if err := d.value(key); err != nil {
+				return res, err
+			}
+			x, err := d.any()
+			if err != nil {
+				return res, err
+			}
+			res[key] = x
+		}
+	default:
+		var res []any
+		for {
+			if end, err := d.next(); err != nil {
+				return res, err
+			} else if end {
+				break
+			}
+			x, err := d.any()
+			if err != nil {
+				return res, err
+			}
+			res = append(res, x)
+		}
+		switch {
+		case res != nil:
+			return res, nil
+		case d.p.LastBrace() == ')':
+			return nil, nil
+		}
+		return []any{}, nil
+	}
+}
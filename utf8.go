@@ -0,0 +1,144 @@
+package xsx
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// skipspace8 is skipspace for Scanner.UTF8 mode.
+func (s *Scanner) skipspace8(txt []byte) (res int) {
+	if s.WsBuf != nil {
+		s.WsBuf.Reset()
+	}
+	for res < len(txt) {
+		n := isSpace8(txt[res:])
+		if n == 0 {
+			return res
+		}
+		if s.WsBuf != nil {
+			s.WsBuf.Write(txt[res : res+n])
+		}
+		res += n
+	}
+	return res
+}
+
+// isSpace8 returns the length of the UTF-8 encoded white space rune at the
+// start of txt or 0 if txt does not start with white space.
+func isSpace8(txt []byte) int {
+	if c := txt[0]; c < utf8.RuneSelf {
+		if isAny(c, ccSpace) {
+			return 1
+		}
+		return 0
+	}
+	if r, n := utf8.DecodeRune(txt); unicode.IsSpace(r) {
+		return n
+	}
+	return 0
+}
+
+// skipUAtom8 is skipUAtom for Scanner.UTF8 mode.
+func skipUAtom8(txt []byte) (atom int) {
+	for atom < len(txt) {
+		c := txt[atom]
+		if c < utf8.RuneSelf {
+			if isAny(c, ccSpace|ccBegin|ccEnd|ccTok) {
+				return atom
+			}
+			atom++
+			continue
+		}
+		r, n := utf8.DecodeRune(txt[atom:])
+		if unicode.IsSpace(r) {
+			return atom
+		}
+		atom += n
+	}
+	return -1
+}
+
+// spaceAhead checks in UTF8 mode if the unquoted atom in s.atomHead ends with
+// the first bytes of a white space rune that continues in txt. It returns
+// the number of bytes at the end of atomHead and at the start of txt that
+// belong to the white space. If it cannot be decided because txt is too
+// short, ok is false.
+func (s *Scanner) spaceAhead(txt []byte) (head, tail int, ok bool) {
+	start := len(s.atomHead) - 1
+	for start > 0 && start > len(s.atomHead)-utf8.UTFMax && !utf8.RuneStart(s.atomHead[start]) {
+		start--
+	}
+	if start < 0 || utf8.FullRune(s.atomHead[start:]) {
+		return 0, 0, true
+	}
+	var buf [utf8.UTFMax]byte
+	rn := copy(buf[:], s.atomHead[start:])
+	rn += copy(buf[rn:], txt)
+	if !utf8.FullRune(buf[:rn]) {
+		return 0, 0, false
+	}
+	head = len(s.atomHead) - start
+	if r, n := utf8.DecodeRune(buf[:rn]); n > head && unicode.IsSpace(r) {
+		return head, n - head, true
+	}
+	return 0, 0, true
+}
+
+// callSplitAtom reports the unquoted atom in s.atomHead without the last head
+// bytes that start a white space rune which continues in the current chunk.
+func (s *Scanner) callSplitAtom(txt []byte, head int) error {
+	atom := s.atomHead[:len(s.atomHead)-head]
+	if len(atom) == 0 {
+		if s.meta {
+			return s.callMetaAtom(txt)
+		}
+		return nil
+	}
+	s.tokStart = s.aheadPos
+	// s.cur is the position after the last chunk
+	s.tokEnd = Position{Offset: s.pos - int64(head), Line: s.cur.Line, Col: s.cur.Col - 1}
+	return s.callAtom(txt, s.meta, atom, false)
+}
+
+// checkUTF8 returns the offset in txt of the first byte of an invalid UTF-8
+// sequence or -1 if txt is valid so far. If the invalid sequence started in
+// an earlier chunk of input at s.u8Pos, prev is true and bad is 0. An
+// incomplete sequence at the end of txt is kept to be checked with the next
+// chunk of input.
+func (s *Scanner) checkUTF8(txt []byte) (bad int64, prev bool) {
+	i := 0
+	if s.u8n > 0 {
+		for i < len(txt) && !utf8.FullRune(s.u8[:s.u8n]) {
+			s.u8[s.u8n] = txt[i]
+			s.u8n++
+			i++
+		}
+		if !utf8.FullRune(s.u8[:s.u8n]) {
+			return -1, false
+		}
+		r, n := utf8.DecodeRune(s.u8[:s.u8n])
+		s.u8n = 0
+		if r == utf8.RuneError && n == 1 {
+			return 0, true
+		}
+	} else if utf8.Valid(txt) {
+		return -1, false
+	}
+	for i < len(txt) {
+		if txt[i] < utf8.RuneSelf {
+			i++
+			continue
+		}
+		r, n := utf8.DecodeRune(txt[i:])
+		if r == utf8.RuneError && n == 1 {
+			if !utf8.FullRune(txt[i:]) {
+				s.u8n = copy(s.u8[:], txt[i:])
+				s.u8Pos = s.at(int64(i))
+				return -1, false
+			}
+			return int64(i), false
+		}
+		i += n
+	}
+	return -1, false
+}
@@ -0,0 +1,166 @@
+package xsx
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/stvp/assert"
+)
+
+func utf8Scanner(wr *bytes.Buffer) *Scanner {
+	var s *Scanner
+	s = NewScanner(
+		func(meta bool, brace byte) { fmt.Fprintf(wr, "begin %t %c\n", meta, brace) },
+		func(meta bool, brace byte) { fmt.Fprintf(wr, "end %c\n", brace) },
+		func(meta bool, atom []byte, quoted bool) {
+			start, end := s.Pos()
+			fmt.Fprintf(wr, "atom %t %q %t %s-%s\n", meta, atom, quoted, start, end)
+		},
+	)
+	s.UTF8 = true
+	return s
+}
+
+func TestScanner_UTF8(t *testing.T) {
+	var out bytes.Buffer
+	s := utf8Scanner(&out)
+	assert.Nil(t, s.ScanString("voilà Å\u00a0x\u2028y\u3000\\\u0085z"))
+	assert.Equal(t, `atom false "voilà" false 1:1-1:6
+atom false "Å" false 1:7-1:8
+atom false "x" false 1:9-1:10
+atom false "y" false 1:11-1:12
+atom false "\\" false 1:13-1:14
+atom false "z" false 1:15-1:16
+`, out.String())
+
+	var atoms []string
+	s = NewScanner(BeginNop, EndNop, func(_ bool, atom []byte, _ bool) {
+		atoms = append(atoms, string(atom))
+	})
+	assert.Nil(t, s.ScanString("voilà"))
+	assert.Equal(t, []string{"voil\xc3"}, atoms)
+}
+
+func TestScanner_UTF8Split(t *testing.T) {
+	checkSplitInvariant(t, utf8Scanner, "(a\u2028bé\u00a0\\\u00a0c\u0085\u3000\\d\u2029)\u00a0e\u00a0")
+}
+
+func TestScanner_CheckUTF8(t *testing.T) {
+	for _, tc := range []struct {
+		in     string
+		atoms  string
+		offset int64
+		where  string
+	}{
+		{"(a b\xffc)", "a", 4, "1:5"},
+		{"héllo \"wo\xc3rld\"", "héllo", 10, "1:10"},
+		{"x\xe2\x80", "", 1, "1:2"},
+		{"x\xe2\x80\xa8y ;\xa0", "x\u2028y", 7, "1:6"},
+		{"a\n\xe2\x80(", "a", 2, "2:1"},
+	} {
+		for split := 0; split <= len(tc.in); split++ {
+			var atoms []string
+			s := NewScanner(BeginNop, EndNop, func(_ bool, atom []byte, _ bool) {
+				atoms = append(atoms, string(atom))
+			})
+			s.CheckUTF8 = true
+			err := s.Scan([]byte(tc.in[:split]))
+			if err == nil {
+				err = s.Scan([]byte(tc.in[split:]))
+			}
+			if err == nil {
+				err = s.Finish()
+			}
+			assert.True(t, errors.Is(err, ScanInvalidUTF8), err)
+			assert.Equal(t, tc.offset, err.(*ScanError).Position(), tc.in, split)
+			assert.Equal(t, tc.where, err.(*ScanError).Where(), tc.in, split)
+			if split == 0 {
+				assert.Equal(t, tc.atoms, strings.Join(atoms, " "), tc.in)
+			}
+		}
+	}
+	s := NewScanner(BeginNop, EndNop, AtomNop)
+	s.CheckUTF8 = true
+	assert.Nil(t, s.Scan([]byte("\xe2")))
+	assert.Nil(t, s.Scan([]byte("\x80")))
+	assert.Nil(t, s.Scan([]byte("\xa8")))
+	assert.Nil(t, s.Finish())
+}
+
+// rescanAtoms returns the atoms that a Scanner reads from txt.
+func rescanAtoms(txt string, utf8, comments, escapes bool) ([]string, error) {
+	var atoms []string
+	s := NewScanner(BeginNop, EndNop, func(_ bool, atom []byte, _ bool) {
+		atoms = append(atoms, string(atom))
+	})
+	s.UTF8, s.Comments, s.Escapes = utf8, comments, escapes
+	err := s.ScanString(txt)
+	return atoms, err
+}
+
+func TestPrinter_rescan(t *testing.T) {
+	pieces := []string{"a", "à", "Å", "\u00a0", "\u0085", "\u2028", "\u3000",
+		" ", "\t", "\n", "\r", "(", ")", "]", "{", "\"", "\\", ";", "x;", "\x00",
+		"\xc3", "\xa0", "é", "1"}
+	rnd := rand.New(rand.NewSource(4711))
+	atoms := make([]string, 500)
+	for i := range atoms {
+		var sb strings.Builder
+		for n := rnd.Intn(5); n >= 0; n-- {
+			sb.WriteString(pieces[rnd.Intn(len(pieces))])
+		}
+		atoms[i] = sb.String()
+	}
+	printers := map[string]func(io.Writer, bool) Printer{
+		"compact": func(w io.Writer, esc bool) Printer {
+			p := Compact(w)
+			p.Escapes = esc
+			return p
+		},
+		"indenting": func(w io.Writer, esc bool) Printer {
+			p := Indenting(w, "  ")
+			p.Escapes = esc
+			return p
+		},
+		"pretty": func(w io.Writer, esc bool) Printer {
+			p := Pretty(w, "  ")
+			p.Escapes = esc
+			return p
+		},
+	}
+	for name, newPrinter := range printers {
+		for _, esc := range []bool{false, true} {
+			var sb strings.Builder
+			p := newPrinter(&sb, esc)
+			assert.Nil(t, p.Begin('(', false))
+			for i, atom := range atoms {
+				assert.Nil(t, p.Atom(atom, false, Qcond))
+				if i%7 == 0 {
+					assert.Nil(t, p.Newline(1, 0))
+				}
+			}
+			assert.Nil(t, p.End())
+			for _, u8 := range []bool{false, true} {
+				for _, cmts := range []bool{false, true} {
+					back, err := rescanAtoms(sb.String(), u8, cmts, esc)
+					assert.Nil(t, err, name)
+					if len(back) != len(atoms) {
+						t.Fatalf("%s esc=%t utf8=%t comments=%t: %d atoms",
+							name, esc, u8, cmts, len(back))
+					}
+					for i := range atoms {
+						if back[i] != atoms[i] {
+							t.Fatalf("%s esc=%t utf8=%t comments=%t: %q != %q",
+								name, esc, u8, cmts, back[i], atoms[i])
+						}
+					}
+				}
+			}
+		}
+	}
+}
@@ -2,9 +2,16 @@ package xsx
 
 import (
 	"bytes"
+	"encoding"
+	"encoding/base64"
 	"fmt"
 	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
 	"unicode"
+	"unicode/utf8"
 )
 
 // EscapeTo escapes characters in str according to the escape rules of XSX
@@ -12,34 +19,97 @@ import (
 // The result is written to the dst output buffer.
 func EscapeTo(str string, dst io.Writer) (numEsc int, err error) {
 	var res int
-	for _, c := range str {
-		switch c {
-		case '"':
-			if _, err = dst.Write([]byte("\\\"")); err != nil {
-				return 0, err
-			}
-			res++
-		case '\\':
-			if _, err = dst.Write([]byte("\\\\")); err != nil {
-				return 0, err
-			}
-			res++
-		default:
-			if _, err = dst.Write([]byte(string(c))); err != nil {
-				return 0, err
-			}
+	for {
+		// bytes are copied as they are, even if str is not valid UTF-8
+		i := strings.IndexAny(str, "\"\\")
+		if i < 0 {
+			break
+		}
+		if _, err = io.WriteString(dst, str[:i]); err != nil {
+			return 0, err
 		}
+		if _, err = dst.Write([]byte{'\\', str[i]}); err != nil {
+			return 0, err
+		}
+		res++
+		str = str[i+1:]
+	}
+	if _, err = io.WriteString(dst, str); err != nil {
+		return 0, err
 	}
 	return res, nil
 }
 
+// EscapeExtTo escapes str according to the extended escape dialect that is
+// enabled with Scanner.Escapes. In addition to '"' and '\\', newline, tab,
+// carriage return and NUL are written as '\n', '\t', '\r' and '\0'. Other
+// non-printable characters are written as '\xHH' if they are ASCII and as
+// '\u{H…}' otherwise. Bytes that are not valid UTF-8 are written as '\xHH'.
+// The escaped string has no line breaks.
+func EscapeExtTo(str string, dst io.Writer) (numEsc int, err error) {
+	var buf []byte
+	for i := 0; i < len(str); {
+		r, n := utf8.DecodeRuneInString(str[i:])
+		switch {
+		case r == utf8.RuneError && n == 1:
+			buf = append(buf, `\x`...)
+			buf = appendHex2(buf, str[i])
+		case r == '"' || r == '\\':
+			buf = append(buf, '\\', byte(r))
+		case r == '\n':
+			buf = append(buf, `\n`...)
+		case r == '\t':
+			buf = append(buf, `\t`...)
+		case r == '\r':
+			buf = append(buf, `\r`...)
+		case r == 0:
+			buf = append(buf, `\0`...)
+		case unicode.IsPrint(r):
+			buf = append(buf, str[i:i+n]...)
+			i += n
+			continue
+		case r < utf8.RuneSelf:
+			buf = append(buf, `\x`...)
+			buf = appendHex2(buf, byte(r))
+		default:
+			buf = append(buf, `\u{`...)
+			buf = strconv.AppendUint(buf, uint64(r), 16)
+			buf = append(buf, '}')
+		}
+		numEsc++
+		i += n
+	}
+	if _, err = dst.Write(buf); err != nil {
+		return 0, err
+	}
+	return numEsc, nil
+}
+
+func appendHex2(buf []byte, b byte) []byte {
+	const hex = "0123456789abcdef"
+	return append(buf, hex[b>>4], hex[b&0xf])
+}
+
+// NeedQuote reports whether str must be quoted to be read back as a single
+// atom. This is the case if str is empty, starts with CommentStart or contains
+// white space, braces, quotes or the Meta character. White space is checked
+// for runes as well as for the bytes 0x85 and 0xA0, i.e. the result is read
+// back correctly with and without Scanner.UTF8.
+//
+// Note that the byte check also quotes atoms with non-space runes whose UTF-8
+// encoding contains one of these bytes, e.g. "voilà" or "Å", because the
+// byte-wise Scanner would split them. Earlier versions wrote such atoms
+// unquoted.
 func NeedQuote(str string) bool {
-	if len(str) == 0 {
+	if len(str) == 0 || str[0] == CommentStart {
+		return true
+	}
+	if strings.IndexByte(str, 0x85) >= 0 || strings.IndexByte(str, 0xA0) >= 0 {
 		return true
 	}
 	for _, c := range str {
 		switch c {
-		case '"', '\\', '(', '[', '{', ' ', '\t':
+		case '"', '\\', '(', '[', '{', ')', ']', '}', ' ', '\t':
 			return true
 
 		default:
@@ -51,6 +121,20 @@ func NeedQuote(str string) bool {
 	return false
 }
 
+// NeedQuoteExt is like NeedQuote but also reports true if str contains
+// characters that EscapeExtTo would escape.
+func NeedQuoteExt(str string) bool {
+	if NeedQuote(str) || !utf8.ValidString(str) {
+		return true
+	}
+	for _, c := range str {
+		if !unicode.IsPrint(c) {
+			return true
+		}
+	}
+	return false
+}
+
 func QuoteTo(str string, wr io.Writer) (err error) {
 	if _, err = wr.Write([]byte("\"")); err != nil {
 		return err
@@ -62,12 +146,26 @@ func QuoteTo(str string, wr io.Writer) (err error) {
 	return err
 }
 
+// QuoteExtTo writes str as quoted atom using EscapeExtTo.
+func QuoteExtTo(str string, wr io.Writer) (err error) {
+	if _, err = wr.Write([]byte("\"")); err != nil {
+		return err
+	}
+	if _, err = EscapeExtTo(str, wr); err != nil {
+		return err
+	}
+	_, err = wr.Write([]byte("\""))
+	return err
+}
+
 func Quoted(str string) string {
 	buf := bytes.NewBuffer(nil)
 	QuoteTo(str, buf)
 	return buf.String()
 }
 
+// CondQuoteTo writes str to wr and quotes it if NeedQuote reports so. Note
+// that this also quotes atoms with runes like 'à' or 'Å', see NeedQuote.
 func CondQuoteTo(str string, wr io.Writer) (quoted bool, err error) {
 	if NeedQuote(str) {
 		err = QuoteTo(str, wr)
@@ -95,6 +193,11 @@ const (
 	Qforce
 	// QSUPPRESS suppresses quoting of an atom. This might break XSX syntax!
 	QSUPPRESS
+	// Always write a raw atom, see RawStart
+	Qraw
+	// Quote only if needed and write a raw atom instead of a quoted atom if
+	// that avoids escapes
+	QcondRaw
 )
 
 type B rune
@@ -105,46 +208,122 @@ type printEnd int
 // will choose the correct bracket to keep them balanced.
 const End printEnd = printEnd(0)
 
+// Comment is passed to Write to write a comment if the Printer is a
+// CommentPrinter. Otherwise the comment is ignored.
+type Comment struct {
+	Text string
+	// Brace is the opening brace of a block comment or 0 for a line comment
+	Brace byte
+}
+
 type Nl struct {
 	Count  int
 	Indent int
 }
 
+// Mv is passed to Write to write its value V as meta expression, i.e. the
+// atom or the outermost sequence that V is written as gets the meta flag.
+type Mv struct {
+	V any
+}
+
+// Write writes the tokens to p. Values that implement Marshaler are written
+// with MarshalXSX and values that implement encoding.TextMarshaler are written
+// as atom with the result of MarshalText, e.g. time.Time is an atom in RFC
+// 3339 format. A time.Duration is an atom in the format of its String method.
+// Like with Marshal, []byte is an atom with the standard base64 encoding of
+// the bytes, other slices and arrays are sequences in square brackets and maps
+// are sequences in curly braces of alternating keys and values, sorted by key.
+// The elements of slices, arrays and maps are written like tokens of Write.
+// Nil slices and maps are written as '()'.
 func Write(p Printer, token ...interface{}) (err error) {
 	for _, t := range token {
-		switch tok := t.(type) {
-		case Nl:
-			if err = p.Newline(tok.Count, tok.Indent); err != nil {
-				return err
-			}
-		case B:
-			if err = p.Begin(rune(tok), false); err != nil {
-				return err
-			}
-		case Bm:
-			if err = p.Begin(rune(tok), true); err != nil {
-				return err
-			}
-		case printEnd:
-			if err = p.End(); err != nil {
-				return err
-			}
-		case string:
-			if err = p.Atom(tok, false, Qcond); err != nil {
-				return err
-			}
-		case int, uint, bool, float32, float64, int8, uint8,
-			int16, uint16, int32, uint32, int64, uint64, uintptr:
-			str := fmt.Sprint(t)
-			if err = p.Atom(str, false, QSUPPRESS); err != nil {
-				return err
-			}
-		default:
-			str := fmt.Sprint(t)
-			if err = p.Atom(str, false, Qcond); err != nil {
-				return err
-			}
+		if err = writeToken(p, t); err != nil {
+			return err
 		}
 	}
 	return nil
 }
+
+func writeToken(p Printer, t any) (err error) {
+	switch tok := t.(type) {
+	case Nl:
+		return p.Newline(tok.Count, tok.Indent)
+	case Comment:
+		if cp, ok := p.(CommentPrinter); ok {
+			return cp.Comment(tok.Text, tok.Brace)
+		}
+		return nil
+	case B:
+		return p.Begin(rune(tok), false)
+	case Bm:
+		return p.Begin(rune(tok), true)
+	case printEnd:
+		return p.End()
+	case Mv:
+		return writeToken(&metaPrinter{Printer: p}, tok.V)
+	case string:
+		return p.Atom(tok, false, Qcond)
+	case Marshaler:
+		return tok.MarshalXSX(p)
+	case encoding.TextMarshaler:
+		return marshalText(p, tok)
+	case time.Duration:
+		return p.Atom(tok.String(), false, Qcond)
+	case []byte:
+		return p.Atom(base64.StdEncoding.EncodeToString(tok), false, Qcond)
+	case int, uint, bool, float32, float64, int8, uint8,
+		int16, uint16, int32, uint32, int64, uint64, uintptr:
+		str := fmt.Sprint(t)
+		return p.Atom(str, false, QSUPPRESS)
+	}
+	switch v := reflect.ValueOf(t); v.Kind() {
+	case reflect.Slice:
+		if v.IsNil() {
+			return marshalNil(p)
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			str := base64.StdEncoding.EncodeToString(v.Bytes())
+			return p.Atom(str, false, Qcond)
+		}
+		return marshalList(p, v, 0, writeElem(p))
+	case reflect.Array:
+		return marshalList(p, v, 0, writeElem(p))
+	case reflect.Map:
+		if v.IsNil() {
+			return marshalNil(p)
+		}
+		return marshalMap(p, v, 0, writeElem(p))
+	}
+	str := fmt.Sprint(t)
+	return p.Atom(str, false, Qcond)
+}
+
+// writeElem writes an element of a slice, array or map like a token.
+func writeElem(p Printer) func(reflect.Value) error {
+	return func(v reflect.Value) error { return writeToken(p, v.Interface()) }
+}
+
+// metaPrinter sets the meta flag of the first atom or sequence written to
+// Printer.
+type metaPrinter struct {
+	Printer
+	done bool
+}
+
+func (p *metaPrinter) Begin(bracket rune, meta bool) error {
+	meta = meta || !p.done
+	p.done = true
+	return p.Printer.Begin(bracket, meta)
+}
+
+func (p *metaPrinter) Atom(atom string, meta bool, quote QuoteMode) error {
+	meta = meta || !p.done
+	p.done = true
+	return p.Printer.Atom(atom, meta, quote)
+}
+
+// Comment implements CommentPrinter.
+func (p *metaPrinter) Comment(text string, brace byte) error {
+	return printerComment(p.Printer, text, brace)
+}
@@ -3,7 +3,11 @@ package xsx
 import (
 	"bytes"
 	"os"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/stvp/assert"
 )
 
 func TestSingleQuote(t *testing.T) {
@@ -43,3 +47,42 @@ func ExampleWrite() {
 	//   \{bar 4711}
 	// )
 }
+
+func ExampleWrite_values() {
+	p := Compact(os.Stdout)
+	mustExample(Write(p, B('('), "request",
+		map[string]any{"path": "/api", "status": 200, "tags": []string{"a", "b c"}},
+		[]byte("data"),
+		time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC),
+		1500*time.Millisecond,
+		Mv{"debug"}, Mv{[2]int{1, 2}},
+		End,
+	))
+	// Output:
+	// (request{path /api status 200 tags[a "b c"]}ZGF0YQ== 2024-05-01T12:30:00Z 1.5s \debug\[1 2])
+}
+
+func TestWrite_values(t *testing.T) {
+	for _, test := range []struct {
+		tok    any
+		expect string
+	}{
+		{[]int(nil), "()"},
+		{map[string]int(nil), "()"},
+		{[]any{}, "[]"},
+		{map[int]bool{10: true, 2: false}, "{10 true 2 false}"},
+		{[]any{B('('), "a", End, Mv{B('[')}, End}, `[(a)\[]]`},
+		{Mv{[]Mv{{"a"}}}, `\[\a]`},
+		{Mv{Comment{Text: "c"}}, ";c\n"},
+		{[]time.Duration{time.Second}, "[1s]"},
+		{[3]byte{1, 2, 3}, "[1 2 3]"},
+		{[]string{"voilà", "Å", "é"}, `["voilà" "Å" é]`},
+	} {
+		var sb strings.Builder
+		assert.Nil(t, Write(Compact(&sb), test.tok))
+		assert.Equal(t, test.expect, sb.String(), test.tok)
+	}
+	err := Write(Compact(new(strings.Builder)), map[[2]int]int{{1, 2}: 3})
+	_, ok := err.(*UnsupportedTypeError)
+	assert.True(t, ok, err)
+}